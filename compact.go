@@ -0,0 +1,67 @@
+package green
+
+// WithCompactThreshold makes Map.Immutable flatten chains of ImmutableMaps
+// derived from the ImmutableMap returned by NewImmutableMap once they grow
+// longer than n. Deriving a Map from an ImmutableMap whose ChainDepth is n or
+// more bases it on the ImmutableMap's Compact instead, which is computed once
+// per ImmutableMap, so the chain never grows longer than n. The option is
+// passed on to all ImmutableMaps canonized from Maps derived from the map,
+// and to their Compact. Lower values speed up lookups on maps which go through
+// many derive-mutate-canonize cycles, at the cost of more frequent O(n)
+// flattening. If n is not positive, or without this option, chains are never
+// flattened automatically.
+//
+// The option only applies to the top level of the map, and has no effect
+// with WithHAMT, whose versions don't form chains.
+func WithCompactThreshold(n int) MapOption {
+	return func(cfg *mapConfig) {
+		cfg.compactThreshold = n
+	}
+}
+
+// ChainDepth returns the number of ImmutableMaps which must be consulted,
+// beyond this one, to look up a key in the ImmutableMap. Each time a Map is
+// derived from an ImmutableMap, mutated, and canonized, the resulting
+// ImmutableMap only holds the changes made, on top of the original, so the
+// chain grows by one. ImmutableMaps created with NewImmutableMap, and those
+// returned by Compact, have a depth of 0. If the ImmutableMap is nil, this
+// returns 0.
+//
+// This has O(1) time complexity.
+func (m *ImmutableMap) ChainDepth() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.depth
+}
+
+// Compact returns an ImmutableMap equal to this one with a ChainDepth of 0,
+// so that lookups no longer walk the chain. A layered map (see NewLayeredMap)
+// is flattened into a single layer likewise. Nested values are shared with the
+// original, not copied. If the chain depth is already 0 and the map is not
+// layered, the ImmutableMap itself is returned. The result is cached, so
+// subsequent calls return the same ImmutableMap.
+//
+// The returned ImmutableMap holds no link to the maps it was flattened from,
+// so Diff and Merge3 visit all of its keys when comparing it to them. See
+// Diff.
+//
+// This has O(n*d) time complexity, where n is the number of key-value pairs in
+// the map and d is its chain depth, or the total number of key-value pairs in
+// the layers of a layered map. Subsequent calls have O(1) time complexity.
+func (m *ImmutableMap) Compact() *ImmutableMap {
+	if m == nil || (m.inherited == nil && m.layers == nil) {
+		return m
+	}
+	if c := m.compacted.Load(); c != nil {
+		return c
+	}
+
+	base := make(map[string]any, m.Len())
+	for k, v := range m.All() {
+		base[k] = v
+	}
+	m.compacted.CompareAndSwap(nil, &ImmutableMap{base: base, compactThreshold: m.compactThreshold})
+	return m.compacted.Load()
+}
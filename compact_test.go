@@ -0,0 +1,92 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	t.Run("ChainDepth and Compact", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{"a": 1, "b": 2, "owner": map[string]any{"name": "Sam"}})
+		assert.Equal(t, 0, im.ChainDepth())
+		assert.Same(t, im, im.Compact())
+
+		owner, ok := im.Get("owner")
+		require.True(t, ok)
+
+		cur := im
+		for i := range 5 {
+			mut := cur.Mutable()
+			mut.Set("count", i)
+			mut.Delete("b")
+			cur = mut.Immutable()
+			assert.Equal(t, i+1, cur.ChainDepth())
+		}
+		assert.Same(t, cur, cur.Mutable().Immutable(), "unchanged maps don't grow the chain")
+
+		compacted := cur.Compact()
+		assert.Same(t, compacted, cur.Compact())
+		assert.Equal(t, 0, compacted.ChainDepth())
+		assert.Equal(t, map[string]any{"a": 1, "count": 4, "owner": map[string]any{"name": "Sam"}}, compacted.Export())
+		assert.True(t, Equal(cur, compacted))
+		assert.Equal(t, 3, compacted.Len())
+		assert.False(t, compacted.Has("b"))
+
+		// nested values are shared, not copied
+		owner2, ok := compacted.Get("owner")
+		require.True(t, ok)
+		assert.Same(t, owner, owner2)
+
+		// a compacted map can be derived from like any other
+		mut := compacted.Mutable()
+		mustGetMapFromMap(t, "owner", mut).Set("name", "Alex")
+		assert.Equal(t, "Alex", mustGetMapFromMap(t, "owner", mut.Immutable().Mutable()).Export()["name"])
+		assert.Equal(t, map[string]any{"name": "Sam"}, owner.(*ImmutableMap).Export())
+
+		var nilMap *ImmutableMap
+		assert.Equal(t, 0, nilMap.ChainDepth())
+		assert.Nil(t, nilMap.Compact())
+	})
+
+	t.Run("WithCompactThreshold", func(t *testing.T) {
+		r := NewRef(NewImmutableMap(map[string]any{"name": "api"}, WithCompactThreshold(3)))
+		depths := make([]int, 0, 8)
+		for i := range 8 {
+			im := r.Update(func(m *Map) { m.Set("count", i) })
+			depths = append(depths, im.ChainDepth())
+		}
+		assert.Equal(t, []int{1, 2, 3, 1, 2, 3, 1, 2}, depths)
+		assert.Equal(t, map[string]any{"name": "api", "count": 7}, r.Load().Export())
+
+		// maps derived from the same base share its Compact, and keep their
+		// link to the base
+		base := r.Load().Mutable()
+		base.Set("count", 8)
+		deep := base.Immutable()
+		require.Equal(t, 3, deep.ChainDepth())
+		var derived []*ImmutableMap
+		for _, k := range []string{"a", "b"} {
+			mut := deep.Mutable()
+			mut.Set(k, true)
+			im := mut.Immutable()
+			assert.Equal(t, 1, im.ChainDepth())
+			assert.Same(t, deep.Compact(), im.inherited.base)
+			assert.Equal(t, []Change{{Kind: ChangeAdded, Path: "/" + k, New: true}}, Diff(deep, im))
+			derived = append(derived, im)
+		}
+		merged, conflicts := Merge3(deep, derived[0], derived[1])
+		assert.Empty(t, conflicts)
+		assert.Equal(t, map[string]any{"name": "api", "count": 8, "a": true, "b": true}, merged.Export())
+
+		// the option is opt-in, and only applies to the map it's given to
+		cur := NewImmutableMap(nil)
+		for i := range 20 {
+			mut := cur.Mutable()
+			mut.Set("count", i)
+			cur = mut.Immutable()
+		}
+		assert.Equal(t, 20, cur.ChainDepth())
+	})
+}
@@ -1,5 +1,27 @@
 package green
 
+import (
+	"iter"
+	"reflect"
+)
+
+type (
+	// mapReader is implemented by the generic map containers, so that they can
+	// be compared with the untyped ones. Values are returned as ImmutableMap.Get
+	// would return them.
+	mapReader interface {
+		Len() int
+		getAny(key string) (any, bool)
+		allAny() iter.Seq2[string, any]
+	}
+
+	// sliceReader is the slice counterpart of mapReader.
+	sliceReader interface {
+		Len() int
+		atAny(index int) any
+	}
+)
+
 // Equal is a green-container-aware equality check between two values. A
 // container is equivalent to another container if the outputs of their Export
 // functions are deeply equal. A container is equivalent to a native Go type if
@@ -24,7 +46,14 @@ func Equal(a, b any) bool {
 		return a.Equal(b)
 	case *Slice:
 		return a.Equal(b)
+	case mapReader:
+		return equalMapReader(a, b)
+	case sliceReader:
+		return equalSliceReader(a, b)
 	default:
+		if t := reflect.TypeOf(a); t != nil && !t.Comparable() {
+			return reflect.DeepEqual(a, b)
+		}
 		return a == b
 	}
 }
@@ -37,6 +66,8 @@ func equalImmuteMap(a *ImmutableMap, b any) bool {
 		return equalImmuteMapToMap(a, b)
 	case map[string]any:
 		return equalImmuteMapToGoMap(a, b)
+	case mapReader:
+		return equalMapReader(b, a)
 	default:
 		return false
 	}
@@ -49,6 +80,9 @@ func equalImmuteMapToImmuteMap(a, b *ImmutableMap) bool {
 	if a.Len() != b.Len() {
 		return false
 	}
+	if a.trie != nil && b.trie != nil {
+		return equalTries(a.trie, b.trie)
+	}
 	for k, aValue := range a.All() {
 		bValue, ok := b.Get(k)
 		if !ok {
@@ -61,6 +95,20 @@ func equalImmuteMapToImmuteMap(a, b *ImmutableMap) bool {
 	return true
 }
 
+// equalTries compares only the keys held outside the nodes shared by a and b.
+func equalTries(a, b *hamt) bool {
+	equal := true
+	hamtChangedKeys(a.root, b.root, 0, func(k string) {
+		if !equal {
+			return
+		}
+		aValue, aOK := a.get(k)
+		bValue, bOK := b.get(k)
+		equal = aOK == bOK && Equal(aValue, bValue)
+	})
+	return equal
+}
+
 func equalImmuteMapToMap(a *ImmutableMap, b *Map) bool {
 	if !b.dirty && b.base == a {
 		return true
@@ -104,6 +152,8 @@ func equalImmuteSlice(a *ImmutableSlice, b any) bool {
 		return equalImmuteSliceToSlice(a, b)
 	case []any:
 		return equalImmuteSliceToGoSlice(a, b)
+	case sliceReader:
+		return equalSliceReader(b, a)
 	default:
 		return false
 	}
@@ -159,6 +209,8 @@ func equalMap(a *Map, b any) bool {
 		return equalMapToMap(a, b)
 	case map[string]any:
 		return equalMapToGoMap(a, b)
+	case mapReader:
+		return equalMapReader(b, a)
 	default:
 		return false
 	}
@@ -207,6 +259,8 @@ func equalSlice(a *Slice, b any) bool {
 		return equalSliceToSlice(a, b)
 	case []any:
 		return equalSliceToGoSlice(a, b)
+	case sliceReader:
+		return equalSliceReader(b, a)
 	default:
 		return false
 	}
@@ -238,3 +292,66 @@ func equalSliceToGoSlice(a *Slice, b []any) bool {
 	}
 	return true
 }
+
+func equalMapReader(a mapReader, b any) bool {
+	var (
+		bLen int
+		bGet func(key string) (any, bool)
+	)
+	switch b := b.(type) {
+	case *ImmutableMap:
+		bLen, bGet = b.Len(), func(key string) (any, bool) { return b.Get(key) }
+	case *Map:
+		bLen, bGet = b.Len(), func(key string) (any, bool) { return b.Get(key) }
+	case map[string]any:
+		bLen, bGet = len(b), func(key string) (any, bool) {
+			v, ok := b[key]
+			return v, ok
+		}
+	case mapReader:
+		bLen, bGet = b.Len(), b.getAny
+	default:
+		return false
+	}
+	if a.Len() != bLen {
+		return false
+	}
+	for k, aValue := range a.allAny() {
+		bValue, ok := bGet(k)
+		if !ok {
+			return false
+		}
+		if !Equal(aValue, bValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalSliceReader(a sliceReader, b any) bool {
+	var (
+		bLen int
+		bAt  func(index int) any
+	)
+	switch b := b.(type) {
+	case *ImmutableSlice:
+		bLen, bAt = b.Len(), func(index int) any { return b.At(index) }
+	case *Slice:
+		bLen, bAt = b.Len(), func(index int) any { return b.At(index) }
+	case []any:
+		bLen, bAt = len(b), func(index int) any { return b[index] }
+	case sliceReader:
+		bLen, bAt = b.Len(), b.atAny
+	default:
+		return false
+	}
+	if a.Len() != bLen {
+		return false
+	}
+	for i := range a.Len() {
+		if !Equal(a.atAny(i), bAt(i)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package green
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// ChangeKind identifies the kind of a Change reported by Diff.
+type ChangeKind int
+
+const (
+	// ChangeAdded indicates that a value exists in the new value but not in
+	// the old value.
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved indicates that a value exists in the old value but not in
+	// the new value.
+	ChangeRemoved
+	// ChangeReplaced indicates that a value exists in both the old and the new
+	// value, but differs.
+	ChangeReplaced
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeReplaced:
+		return "replaced"
+	default:
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Change describes a single difference between two values found by Diff.
+type Change struct {
+	Kind ChangeKind
+	// Path is the JSON Pointer (RFC 6901) of the changed value.
+	Path string
+	// Old is the value before the change. It is nil for ChangeAdded.
+	Old ImmutableValue
+	// New is the value after the change. It is nil for ChangeRemoved.
+	New ImmutableValue
+}
+
+// Diff walks the old value a and the new value b and returns the changes
+// required to turn a into b. Maps are compared key by key and slices index by
+// index; any other difference, including a change of type, is reported as a
+// ChangeReplaced of the whole value. Mutable and native Go containers are
+// converted to immutable containers first.
+//
+// The changes are ordered so that applying them in sequence turns a into b:
+// map keys are visited in sorted order, and elements removed from the end of a
+// slice are reported from the last index to the first.
+//
+// Like Equal, Diff uses pointer equality to skip subtrees which are shared
+// between a and b. If b was canonized from a Map derived from a (or vice
+// versa), only the keys written on that Map are visited at each level. Thus,
+// diffing an ImmutableMap against the result of a few mutations on it costs
+// O(k) rather than O(n), where k is the number of nodes on dirty paths.
+// Compact drops this fast path: its result holds no link to the maps it
+// flattens, so diffing it against them visits all keys. Maps which
+// Map.Immutable bases on a Compact per WithCompactThreshold keep the fast
+// path against the map they were derived from.
+//
+// This has O(n) time complexity in the worst case, where n is the number of
+// nodes in the graphs of a and b.
+func Diff(a, b ImmutableValue) []Change {
+	return diffValues(nil, asImmutable(a), asImmutable(b), nil)
+}
+
+func diffValues(path []string, a, b ImmutableValue, changes []Change) []Change {
+	switch av := a.(type) {
+	case *ImmutableMap:
+		if bv, ok := b.(*ImmutableMap); ok {
+			return diffMaps(path, av, bv, changes)
+		}
+	case *ImmutableSlice:
+		if bv, ok := b.(*ImmutableSlice); ok {
+			return diffSlices(path, av, bv, changes)
+		}
+	}
+	if Equal(a, b) {
+		return changes
+	}
+	return append(changes, Change{Kind: ChangeReplaced, Path: formatPointer(path), Old: a, New: b})
+}
+
+func diffMaps(path []string, a, b *ImmutableMap, changes []Change) []Change {
+	if a == b {
+		return changes
+	}
+
+	for _, k := range diffKeys(a, b) {
+		aValue, aOK := a.Get(k)
+		bValue, bOK := b.Get(k)
+		switch {
+		case aOK && bOK:
+			changes = diffValues(append(path, k), aValue, bValue, changes)
+		case aOK:
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: formatPointer(append(path, k)), Old: aValue})
+		case bOK:
+			changes = append(changes, Change{Kind: ChangeAdded, Path: formatPointer(append(path, k)), New: bValue})
+		}
+	}
+	return changes
+}
+
+// diffKeys returns, in sorted order, the keys whose values may differ between
+// a and b.
+func diffKeys(a, b *ImmutableMap) []string {
+	switch {
+	case derivedFrom(b, a):
+		// b was canonized from a Map derived from a, so only keys written on
+		// that Map can differ
+		return slices.Sorted(maps.Keys(b.inherited.overwrites))
+	case derivedFrom(a, b):
+		return slices.Sorted(maps.Keys(a.inherited.overwrites))
+	case a.trie != nil && b.trie != nil:
+		// versions of the same trie share the nodes which hold keys that
+		// can't differ
+		var keys []string
+		hamtChangedKeys(a.trie.root, b.trie.root, 0, func(k string) {
+			keys = append(keys, k)
+		})
+		slices.Sort(keys)
+		return slices.Compact(keys)
+	}
+
+	keys := make([]string, 0, max(a.Len(), b.Len()))
+	for k := range a.All() {
+		keys = append(keys, k)
+	}
+	for k := range b.All() {
+		if !a.Has(k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func diffSlices(path []string, a, b *ImmutableSlice, changes []Change) []Change {
+	if a == b {
+		return changes
+	}
+
+	n := min(a.Len(), b.Len())
+	for i := range n {
+		changes = diffValues(append(path, strconv.Itoa(i)), a.At(i), b.At(i), changes)
+	}
+	for i := n; i < b.Len(); i++ {
+		changes = append(changes, Change{Kind: ChangeAdded, Path: formatPointer(append(path, strconv.Itoa(i))), New: b.At(i)})
+	}
+	for i := a.Len() - 1; i >= n; i-- {
+		changes = append(changes, Change{Kind: ChangeRemoved, Path: formatPointer(append(path, strconv.Itoa(i))), Old: a.At(i)})
+	}
+	return changes
+}
+
+// derivedFrom reports whether m was canonized from a Map derived from base,
+// or from its Compact, which Map.Immutable may use in its place.
+func derivedFrom(m, base *ImmutableMap) bool {
+	if m.inherited == nil {
+		return false
+	}
+	return m.inherited.base == base || m.inherited.base == base.compacted.Load()
+}
@@ -0,0 +1,112 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed":  "Great Pyrenees",
+			"age":    6,
+			"tricks": []any{"sit", "shake", "roll"},
+			"owner":  map[string]any{"name": "Sam", "city": "Bern"},
+			"vet":    map[string]any{"name": "Dr. Who"},
+		}
+	}
+
+	t.Run("identical values", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		assert.Empty(t, Diff(im, im))
+		assert.Empty(t, Diff(im, NewImmutableMap(newSource())))
+		assert.Empty(t, Diff(im, im.Mutable().Immutable()))
+		assert.Empty(t, Diff("foo", "foo"))
+	})
+
+	t.Run("derived from base", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+		mut.Set("age", 7)
+		mut.Delete("breed")
+		mut.Set("name", "Rex")
+		require.NoError(t, mut.SetPath("/owner/name", "Alex"))
+		require.NoError(t, mut.SetPath("/tricks/-", "speak"))
+		_, err := mut.GetPath("/vet/name") // wrapped but not modified
+		require.NoError(t, err)
+		im2 := mut.Immutable()
+
+		oldOwnerName, err := im.GetPath("/owner/name")
+		require.NoError(t, err)
+		expect := []Change{
+			{Kind: ChangeReplaced, Path: "/age", Old: 6, New: 7},
+			{Kind: ChangeRemoved, Path: "/breed", Old: "Great Pyrenees"},
+			{Kind: ChangeAdded, Path: "/name", New: "Rex"},
+			{Kind: ChangeReplaced, Path: "/owner/name", Old: oldOwnerName, New: "Alex"},
+			{Kind: ChangeAdded, Path: "/tricks/3", New: "speak"},
+		}
+		assert.Equal(t, expect, Diff(im, im2))
+
+		reversed := Diff(im2, im)
+		require.Len(t, reversed, 5)
+		assert.Equal(t, Change{Kind: ChangeAdded, Path: "/breed", New: "Great Pyrenees"}, reversed[1])
+		assert.Equal(t, Change{Kind: ChangeRemoved, Path: "/tricks/3", Old: "speak"}, reversed[4])
+
+		// unrelated but equal maps produce the same changes
+		assert.Equal(t, expect, Diff(NewImmutableMap(newSource()), im2))
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		a := NewImmutableSlice([]any{"a", map[string]any{"k": 1}, "c", "d"})
+		b := NewImmutableSlice([]any{"a", map[string]any{"k": 2}})
+
+		assert.Equal(t, []Change{
+			{Kind: ChangeReplaced, Path: "/1/k", Old: 1, New: 2},
+			{Kind: ChangeRemoved, Path: "/3", Old: "d"},
+			{Kind: ChangeRemoved, Path: "/2", Old: "c"},
+		}, Diff(a, b))
+
+		assert.Equal(t, []Change{
+			{Kind: ChangeReplaced, Path: "/1/k", Old: 2, New: 1},
+			{Kind: ChangeAdded, Path: "/2", New: "c"},
+			{Kind: ChangeAdded, Path: "/3", New: "d"},
+		}, Diff(b, a))
+	})
+
+	t.Run("type changes", func(t *testing.T) {
+		a := NewImmutableMap(map[string]any{"x": map[string]any{"k": 1}, "y": "str"})
+		b := NewImmutableMap(map[string]any{"x": []any{1}, "y": map[string]any{"k": 1}})
+
+		changes := Diff(a, b)
+		require.Len(t, changes, 2)
+		assert.Equal(t, ChangeReplaced, changes[0].Kind)
+		assert.Equal(t, "/x", changes[0].Path)
+		assert.IsType(t, &ImmutableMap{}, changes[0].Old)
+		assert.IsType(t, &ImmutableSlice{}, changes[0].New)
+		assert.Equal(t, "/y", changes[1].Path)
+
+		root := Diff(a, NewImmutableSlice(nil))
+		require.Len(t, root, 1)
+		assert.Equal(t, "", root[0].Path)
+	})
+
+	t.Run("mutable and native inputs", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+		mut.Set("age", 7)
+		expect := []Change{{Kind: ChangeReplaced, Path: "/age", Old: 6, New: 7}}
+		assert.Equal(t, expect, Diff(im, mut))
+		assert.Equal(t, expect, Diff(newSource(), mut.Export()))
+	})
+
+	t.Run("escaped paths", func(t *testing.T) {
+		a := NewImmutableMap(map[string]any{"a/b": 1, "m~n": 1})
+		b := NewImmutableMap(map[string]any{"a/b": 2, "m~n": 2})
+		changes := Diff(a, b)
+		require.Len(t, changes, 2)
+		assert.Equal(t, "/a~1b", changes[0].Path)
+		assert.Equal(t, "/m~0n", changes[1].Path)
+	})
+}
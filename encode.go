@@ -0,0 +1,337 @@
+package green
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// EncodeOption configures EncodeJSON.
+	EncodeOption func(*encodeConfig)
+
+	encodeConfig struct {
+		sortKeys bool
+		indent   bool
+		prefix   string
+		indentBy string
+	}
+
+	// jsonWriter is the set of methods the encoder writes with. Writers which
+	// do not implement it are buffered.
+	jsonWriter interface {
+		io.Writer
+		io.ByteWriter
+		io.StringWriter
+	}
+
+	jsonEncoder struct {
+		w     jsonWriter
+		cfg   encodeConfig
+		depth int
+		buf   bytes.Buffer
+	}
+
+	jsonMember[V any] struct {
+		key string
+		val V
+	}
+)
+
+// WithSortedKeys makes EncodeJSON write map keys in sorted order, as
+// json.Marshal does. By default, keys are written in iteration order.
+func WithSortedKeys() EncodeOption {
+	return func(c *encodeConfig) {
+		c.sortKeys = true
+	}
+}
+
+// WithIndent makes EncodeJSON indent its output, with the same semantics as
+// json.MarshalIndent.
+func WithIndent(prefix, indent string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.indent = true
+		c.prefix = prefix
+		c.indentBy = indent
+	}
+}
+
+// EncodeJSON writes the JSON encoding of v to w. Unlike json.Marshal, green
+// containers are walked directly rather than being converted to native Go
+// types first, and the cached MarshalJSON output of clean immutable subtrees is
+// reused rather than re-encoded. Values which are not green or native Go
+// containers are encoded with json.Marshal. No trailing newline is written.
+//
+// If an error occurs, part of the encoding may already have been written to
+// w.
+//
+// This has O(n) time complexity, where n is the total number of nodes in the
+// graph representing the value which are not already cached.
+func EncodeJSON(w io.Writer, v any, opts ...EncodeOption) error {
+	var cfg encodeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	jw, ok := w.(jsonWriter)
+	var bw *bufio.Writer
+	if !ok {
+		bw = bufio.NewWriter(w)
+		jw = bw
+	}
+
+	e := &jsonEncoder{w: jw, cfg: cfg}
+	if err := e.encode(v); err != nil {
+		return err
+	}
+
+	if bw != nil {
+		return bw.Flush()
+	}
+	return nil
+}
+
+// marshalJSON is the MarshalJSON implementation shared by the green
+// containers. Its output is identical to that of json.Marshal on the exported
+// value.
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	e := &jsonEncoder{w: &buf, cfg: encodeConfig{sortKeys: true}}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *jsonEncoder) encode(v any) error {
+	switch v := v.(type) {
+	case *ImmutableMap:
+		if v == nil {
+			return e.writeString("null")
+		}
+		if b, ok := v.cachedJSON(); ok {
+			return e.writeCached(b)
+		}
+		return encodeMap(e, v.Len(), v.All())
+	case *ImmutableSlice:
+		if v == nil {
+			return e.writeString("null")
+		}
+		if b, ok := v.cachedJSON(); ok {
+			return e.writeCached(b)
+		}
+		return encodeSlice(e, v.Len(), v.All())
+	case *Map:
+		if v == nil {
+			return e.writeString("null")
+		}
+		if !v.dirty {
+			return e.encode(v.base)
+		}
+		return encodeMap(e, v.Len(), v.allRaw())
+	case *Slice:
+		if v == nil {
+			return e.writeString("null")
+		}
+		if !v.dirty {
+			return e.encode(v.base)
+		}
+		return encodeSlice(e, v.Len(), v.allRaw())
+	case map[string]any:
+		if v == nil {
+			return e.writeString("null")
+		}
+		return encodeMap(e, len(v), maps.All(v))
+	case []any:
+		if v == nil {
+			return e.writeString("null")
+		}
+		return encodeSlice(e, len(v), slices.All(v))
+	case nil:
+		return e.writeString("null")
+	case string:
+		return e.writeQuoted(v)
+	case bool:
+		return e.writeString(strconv.FormatBool(v))
+	case int:
+		return e.writeString(strconv.Itoa(v))
+	case int64:
+		return e.writeString(strconv.FormatInt(v, 10))
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return e.writeCached(b)
+	}
+}
+
+func encodeMap[V any](e *jsonEncoder, n int, seq iter.Seq2[string, V]) error {
+	if n == 0 {
+		return e.writeString("{}")
+	}
+
+	if e.cfg.sortKeys {
+		members := make([]jsonMember[V], 0, n)
+		for k, v := range seq {
+			members = append(members, jsonMember[V]{key: k, val: v})
+		}
+		slices.SortFunc(members, func(a, b jsonMember[V]) int {
+			return cmp.Compare(a.key, b.key)
+		})
+		seq = func(yield func(string, V) bool) {
+			for _, m := range members {
+				if !yield(m.key, m.val) {
+					return
+				}
+			}
+		}
+	}
+
+	if err := e.w.WriteByte('{'); err != nil {
+		return err
+	}
+	e.depth++
+	first := true
+	for k, v := range seq {
+		if err := e.writeSeparator(first); err != nil {
+			return err
+		}
+		first = false
+		if err := e.writeQuoted(k); err != nil {
+			return err
+		}
+		if err := e.w.WriteByte(':'); err != nil {
+			return err
+		}
+		if e.cfg.indent {
+			if err := e.w.WriteByte(' '); err != nil {
+				return err
+			}
+		}
+		if err := e.encode(v); err != nil {
+			return err
+		}
+	}
+	e.depth--
+	if err := e.writeNewline(); err != nil {
+		return err
+	}
+	return e.w.WriteByte('}')
+}
+
+func encodeSlice[V any](e *jsonEncoder, n int, seq iter.Seq2[int, V]) error {
+	if n == 0 {
+		return e.writeString("[]")
+	}
+
+	if err := e.w.WriteByte('['); err != nil {
+		return err
+	}
+	e.depth++
+	for i, v := range seq {
+		if err := e.writeSeparator(i == 0); err != nil {
+			return err
+		}
+		if err := e.encode(v); err != nil {
+			return err
+		}
+	}
+	e.depth--
+	if err := e.writeNewline(); err != nil {
+		return err
+	}
+	return e.w.WriteByte(']')
+}
+
+// writeSeparator writes what precedes a map member or slice element.
+func (e *jsonEncoder) writeSeparator(first bool) error {
+	if !first {
+		if err := e.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	return e.writeNewline()
+}
+
+// writeNewline writes a newline followed by the indentation for the current
+// depth, if indenting.
+func (e *jsonEncoder) writeNewline() error {
+	if !e.cfg.indent {
+		return nil
+	}
+	if err := e.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	if err := e.writeString(e.cfg.prefix); err != nil {
+		return err
+	}
+	for range e.depth {
+		if err := e.writeString(e.cfg.indentBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCached writes already encoded JSON, reformatting it to match the
+// output format.
+func (e *jsonEncoder) writeCached(b []byte) error {
+	e.buf.Reset()
+	var err error
+	if e.cfg.indent {
+		prefix := e.cfg.prefix + strings.Repeat(e.cfg.indentBy, e.depth)
+		err = json.Indent(&e.buf, b, prefix, e.cfg.indentBy)
+	} else {
+		err = json.Compact(&e.buf, b)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf.Bytes())
+	return err
+}
+
+// writeQuoted writes s as a JSON string, escaped as json.Marshal does.
+func (e *jsonEncoder) writeQuoted(s string) error {
+	if !isPlainJSONString(s) {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(b)
+		return err
+	}
+	if err := e.w.WriteByte('"'); err != nil {
+		return err
+	}
+	if err := e.writeString(s); err != nil {
+		return err
+	}
+	return e.w.WriteByte('"')
+}
+
+func (e *jsonEncoder) writeString(s string) error {
+	_, err := e.w.WriteString(s)
+	return err
+}
+
+// isPlainJSONString returns whether s can be written between quotes without
+// any escaping.
+func isPlainJSONString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package green
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed":  "Great Pyrenees",
+			"tricks": []any{"sit", "shake", map[string]any{"name": "roll"}},
+			"owner":  map[string]any{"name": "Sam <sam@example.com>", "age": 41},
+			"empty":  map[string]any{},
+			"none":   []any{},
+			"weight": 52.5,
+			"good":   true,
+			"vet":    nil,
+		}
+	}
+
+	t.Run("matches json.Marshal", func(t *testing.T) {
+		expect, err := json.Marshal(newSource())
+		require.NoError(t, err)
+		expectIndent, err := json.MarshalIndent(newSource(), "> ", "\t")
+		require.NoError(t, err)
+
+		mut := NewImmutableMap(newSource()).Mutable()
+		mut.Set("dirty", true)
+		mut.Delete("dirty")
+		for _, v := range []any{newSource(), NewImmutableMap(newSource()), NewImmutableMap(newSource()).Mutable(), mut} {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeJSON(&buf, v, WithSortedKeys()))
+			assert.Equal(t, string(expect), buf.String(), "%T", v)
+
+			buf.Reset()
+			require.NoError(t, EncodeJSON(&buf, v, WithSortedKeys(), WithIndent("> ", "\t")))
+			assert.Equal(t, string(expectIndent), buf.String(), "%T", v)
+		}
+
+		got, err := json.Marshal(NewImmutableMap(newSource()))
+		require.NoError(t, err)
+		assert.Equal(t, string(expect), string(got))
+		got, err = json.Marshal(mut)
+		require.NoError(t, err)
+		assert.Equal(t, string(expect), string(got))
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		is := NewImmutableSlice([]any{"a", map[string]any{"b": []any{1, 2}}})
+		mut := is.Mutable()
+		mut.PushFront("front")
+		mut.Push(NewImmutableMap(map[string]any{"c": 3}))
+
+		var buf bytes.Buffer
+		require.NoError(t, EncodeJSON(&buf, is))
+		assert.Equal(t, `["a",{"b":[1,2]}]`, buf.String())
+
+		buf.Reset()
+		require.NoError(t, EncodeJSON(&buf, mut))
+		assert.Equal(t, `["front","a",{"b":[1,2]},{"c":3}]`, buf.String())
+
+		got, err := json.Marshal(mut)
+		require.NoError(t, err)
+		assert.Equal(t, `["front","a",{"b":[1,2]},{"c":3}]`, string(got))
+	})
+
+	t.Run("unsorted keys", func(t *testing.T) {
+		m := map[string]any{"a": 1, "b": 2, "c": 3}
+		var buf bytes.Buffer
+		require.NoError(t, EncodeJSON(&buf, NewImmutableMap(m)))
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, map[string]any{"a": 1.0, "b": 2.0, "c": 3.0}, decoded)
+	})
+
+	t.Run("reuses cached subtrees", func(t *testing.T) {
+		// the original bytes are not sorted, so reuse is observable
+		im, err := NewImmutableMapFromJSON([]byte(`{"z":{"y":1,"x":2},"a":[3,2,1]}`))
+		require.NoError(t, err)
+		mut := im.Mutable()
+		mut.Set("b", "new")
+
+		var buf bytes.Buffer
+		require.NoError(t, EncodeJSON(&buf, mut, WithSortedKeys()))
+		assert.Equal(t, `{"a":[3,2,1],"b":"new","z":{"y":1,"x":2}}`, buf.String())
+
+		buf.Reset()
+		require.NoError(t, EncodeJSON(&buf, mut, WithSortedKeys(), WithIndent("", "  ")))
+		expect := "{\n  \"a\": [\n    3,\n    2,\n    1\n  ],\n  \"b\": \"new\",\n  \"z\": {\n    \"y\": 1,\n    \"x\": 2\n  }\n}"
+		assert.Equal(t, expect, buf.String())
+
+		// a clean Map encodes its cached base
+		buf.Reset()
+		require.NoError(t, EncodeJSON(&buf, im.Mutable()))
+		assert.Equal(t, `{"z":{"y":1,"x":2},"a":[3,2,1]}`, buf.String())
+	})
+
+	t.Run("writers without byte methods", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, EncodeJSON(onlyWriter{&sb}, NewImmutableSlice([]any{1, "two"})))
+		assert.Equal(t, `[1,"two"]`, sb.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := EncodeJSON(&buf, NewImmutableMap(map[string]any{"a": math.NaN()}))
+		var unsupported *json.UnsupportedValueError
+		assert.True(t, errors.As(err, &unsupported), "%v", err)
+
+		_, err = NewImmutableSlice([]any{math.Inf(1)}).MarshalJSON()
+		assert.Error(t, err)
+	})
+}
+
+// onlyWriter hides all methods of the wrapped writer except Write.
+type onlyWriter struct {
+	w interface{ Write([]byte) (int, error) }
+}
+
+func (o onlyWriter) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
@@ -0,0 +1,412 @@
+package green
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// ErrLossyConversion indicates that a numeric value cannot be represented
+// exactly in the requested type, e.g. 1.5 requested as an int64.
+var ErrLossyConversion = errors.New("lossy numeric conversion")
+
+// GetString returns the string stored under the given key. If the key does not
+// exist or its value is not a string, a *PathError wrapping ErrNotFound or
+// ErrWrongType is returned.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetString(key string) (string, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toString)
+}
+
+// GetInt64 returns the number stored under the given key as an int64. Any
+// integer or floating point type, and json.Number, is accepted as long as the
+// value converts exactly; otherwise a *PathError wrapping ErrLossyConversion is
+// returned. Missing keys and non-numeric values result in a *PathError wrapping
+// ErrNotFound or ErrWrongType.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetInt64(key string) (int64, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toInt64)
+}
+
+// GetFloat64 returns the number stored under the given key as a float64. Any
+// integer or floating point type, and json.Number, is accepted as long as the
+// value converts exactly; otherwise a *PathError wrapping ErrLossyConversion is
+// returned. A json.Number holding a decimal fraction such as 0.1 is rounded to
+// the nearest float64, like a Go float literal. Missing keys and non-numeric
+// values result in a *PathError wrapping ErrNotFound or ErrWrongType.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetFloat64(key string) (float64, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toFloat64)
+}
+
+// GetBool returns the bool stored under the given key. If the key does not
+// exist or its value is not a bool, a *PathError wrapping ErrNotFound or
+// ErrWrongType is returned.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetBool(key string) (bool, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toBool)
+}
+
+// GetMap returns the map stored under the given key. If the key does not exist
+// or its value is not a map, a *PathError wrapping ErrNotFound or ErrWrongType
+// is returned.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetMap(key string) (*ImmutableMap, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toContainer[*ImmutableMap])
+}
+
+// GetSlice returns the slice stored under the given key. If the key does not
+// exist or its value is not a slice, a *PathError wrapping ErrNotFound or
+// ErrWrongType is returned.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMap) GetSlice(key string) (*ImmutableSlice, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toContainer[*ImmutableSlice])
+}
+
+// GetString is like ImmutableMap.GetString.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetString(key string) (string, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toString)
+}
+
+// GetInt64 is like ImmutableMap.GetInt64.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetInt64(key string) (int64, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toInt64)
+}
+
+// GetFloat64 is like ImmutableMap.GetFloat64.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetFloat64(key string) (float64, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toFloat64)
+}
+
+// GetBool is like ImmutableMap.GetBool.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetBool(key string) (bool, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toBool)
+}
+
+// GetMap is like ImmutableMap.GetMap, but returns the mutable Map that Get
+// returns.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetMap(key string) (*Map, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toContainer[*Map])
+}
+
+// GetSlice is like ImmutableMap.GetSlice, but returns the mutable Slice that
+// Get returns.
+//
+// This has O(1) average time complexity.
+func (m *Map) GetSlice(key string) (*Slice, error) {
+	v, ok := m.Get(key)
+	return typedValue(key, v, ok, toContainer[*Slice])
+}
+
+// AtString returns the string at the given index. Unlike At, an index out of
+// range does not panic, but results in a *PathError wrapping ErrNotFound. A
+// value which is not a string results in a *PathError wrapping ErrWrongType.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtString(index int) (string, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toString)
+}
+
+// AtInt64 is like ImmutableMap.GetInt64, but for the element at the given
+// index. See AtString for the handling of out of range indexes.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtInt64(index int) (int64, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toInt64)
+}
+
+// AtFloat64 is like ImmutableMap.GetFloat64, but for the element at the given
+// index. See AtString for the handling of out of range indexes.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtFloat64(index int) (float64, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toFloat64)
+}
+
+// AtBool is like ImmutableMap.GetBool, but for the element at the given
+// index. See AtString for the handling of out of range indexes.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtBool(index int) (bool, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toBool)
+}
+
+// AtMap is like ImmutableMap.GetMap, but for the element at the given index.
+// See AtString for the handling of out of range indexes.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtMap(index int) (*ImmutableMap, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toContainer[*ImmutableMap])
+}
+
+// AtSlice is like ImmutableMap.GetSlice, but for the element at the given
+// index. See AtString for the handling of out of range indexes.
+//
+// This has O(1) average time complexity.
+func (s *ImmutableSlice) AtSlice(index int) (*ImmutableSlice, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toContainer[*ImmutableSlice])
+}
+
+// AtString is like ImmutableSlice.AtString.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtString(index int) (string, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toString)
+}
+
+// AtInt64 is like ImmutableSlice.AtInt64.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtInt64(index int) (int64, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toInt64)
+}
+
+// AtFloat64 is like ImmutableSlice.AtFloat64.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtFloat64(index int) (float64, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toFloat64)
+}
+
+// AtBool is like ImmutableSlice.AtBool.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtBool(index int) (bool, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toBool)
+}
+
+// AtMap is like ImmutableSlice.AtMap, but returns the mutable Map that At
+// returns.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtMap(index int) (*Map, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toContainer[*Map])
+}
+
+// AtSlice is like ImmutableSlice.AtSlice, but returns the mutable Slice that
+// At returns.
+//
+// This has O(1) average time complexity.
+func (s *Slice) AtSlice(index int) (*Slice, error) {
+	v, ok := s.at(index)
+	return typedValue(strconv.Itoa(index), v, ok, toContainer[*Slice])
+}
+
+func (s *ImmutableSlice) at(index int) (ImmutableValue, bool) {
+	if index < 0 || index >= s.Len() {
+		return nil, false
+	}
+	return s.At(index), true
+}
+
+func (s *Slice) at(index int) (Value, bool) {
+	if index < 0 || index >= s.Len() {
+		return nil, false
+	}
+	return s.At(index), true
+}
+
+// typedValue converts the value found under tok, reporting failures as a
+// *PathError.
+func typedValue[T any](tok string, v any, found bool, convert func(any) (T, error)) (T, error) {
+	if !found {
+		var zero T
+		return zero, newPathError([]string{tok}, ErrNotFound)
+	}
+	t, err := convert(v)
+	if err != nil {
+		return t, newPathError([]string{tok}, err)
+	}
+	return t, nil
+}
+
+func toString(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", wrongTypeConversionError(v, "string")
+	}
+	return s, nil
+}
+
+func toBool(v any) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, wrongTypeConversionError(v, "bool")
+	}
+	return b, nil
+}
+
+func toContainer[T any](v any) (T, error) {
+	c, ok := v.(T)
+	if !ok {
+		var zero T
+		return zero, wrongTypeConversionError(v, describeType(zero))
+	}
+	return c, nil
+}
+
+func toInt64(v any) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return uint64ToInt64(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return uint64ToInt64(v)
+	case float32:
+		return float64ToInt64(float64(v))
+	case float64:
+		return float64ToInt64(v)
+	case json.Number:
+		return jsonNumberToInt64(v)
+	default:
+		return 0, wrongTypeConversionError(v, "number")
+	}
+}
+
+func toFloat64(v any) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64ToFloat64(int64(v))
+	case int64:
+		return int64ToFloat64(v)
+	case uint:
+		return uint64ToFloat64(uint64(v))
+	case uint64:
+		return uint64ToFloat64(v)
+	case json.Number:
+		return jsonNumberToFloat64(v)
+	default:
+		// the remaining numeric types always convert exactly
+		f, ok := numberAsFloat64(v)
+		if !ok {
+			return 0, wrongTypeConversionError(v, "number")
+		}
+		return f, nil
+	}
+}
+
+func uint64ToInt64(u uint64) (int64, error) {
+	if u > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %d as int64", ErrLossyConversion, u)
+	}
+	return int64(u), nil
+}
+
+func float64ToInt64(f float64) (int64, error) {
+	// -2^63 is exactly representable, but 2^63 overflows
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %v as int64", ErrLossyConversion, f)
+	}
+	return int64(f), nil
+}
+
+// jsonNumberToInt64 converts n exactly, including integers written with a
+// fraction or exponent, such as 1.0 or 1e3, which Int64 rejects.
+func jsonNumberToInt64(n json.Number) (int64, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	// an int64 is exact in 64 bits of precision, so any rounding means loss
+	f, _, err := big.ParseFloat(string(n), 10, 64, big.ToNearestEven)
+	if err == nil && f.Acc() == big.Exact && f.IsInt() {
+		if i, acc := f.Int64(); acc == big.Exact {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q as int64", ErrLossyConversion, n)
+}
+
+// jsonNumberToFloat64 converts n to the nearest float64. Integers must convert
+// exactly, like those of integer types. Other decimal fractions, such as 0.1,
+// mostly have no exact binary representation, so they are rounded like Go
+// float literals.
+func jsonNumberToFloat64(n json.Number) (float64, error) {
+	if i, err := n.Int64(); err == nil {
+		return int64ToFloat64(i)
+	}
+	f, _, err := big.ParseFloat(string(n), 10, 53, big.ToNearestEven)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q as float64", ErrLossyConversion, n)
+	}
+	x, _ := f.Float64()
+	if math.IsInf(x, 0) || (f.IsInt() && f.Acc() != big.Exact) {
+		return 0, fmt.Errorf("%w: %q as float64", ErrLossyConversion, n)
+	}
+	return x, nil
+}
+
+func int64ToFloat64(i int64) (float64, error) {
+	f := float64(i)
+	if f >= math.MaxInt64 || int64(f) != i {
+		return 0, fmt.Errorf("%w: %d as float64", ErrLossyConversion, i)
+	}
+	return f, nil
+}
+
+func uint64ToFloat64(u uint64) (float64, error) {
+	f := float64(u)
+	if f >= math.MaxUint64 || uint64(f) != u {
+		return 0, fmt.Errorf("%w: %d as float64", ErrLossyConversion, u)
+	}
+	return f, nil
+}
+
+func wrongTypeConversionError(v any, want string) error {
+	return fmt.Errorf("%w: %s is not a %s", ErrWrongType, describeType(v), want)
+}
@@ -0,0 +1,207 @@
+package green
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetters(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed":   "Great Pyrenees",
+			"age":     6,
+			"decoded": 7.0,
+			"weight":  52.5,
+			"number":  json.Number("8"),
+			"huge":    uint64(math.MaxUint64),
+			"precise": int64(1<<53 + 1),
+			"good":    true,
+			"vet":     nil,
+			"owner":   map[string]any{"name": "Sam"},
+			"tricks":  []any{"sit", 2, 2.5, false, map[string]any{}, []any{}},
+		}
+	}
+
+	t.Run("ImmutableMap", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+
+		s, err := im.GetString("breed")
+		require.NoError(t, err)
+		assert.Equal(t, "Great Pyrenees", s)
+
+		for key, expect := range map[string]int64{"age": 6, "decoded": 7, "number": 8, "precise": 1<<53 + 1} {
+			i, err := im.GetInt64(key)
+			require.NoError(t, err, key)
+			assert.Equal(t, expect, i, key)
+		}
+
+		for key, expect := range map[string]float64{"age": 6, "decoded": 7, "weight": 52.5, "number": 8} {
+			f, err := im.GetFloat64(key)
+			require.NoError(t, err, key)
+			assert.Equal(t, expect, f, key)
+		}
+
+		b, err := im.GetBool("good")
+		require.NoError(t, err)
+		assert.True(t, b)
+
+		owner, err := im.GetMap("owner")
+		require.NoError(t, err)
+		ownerGet, _ := im.Get("owner")
+		assert.Same(t, ownerGet, owner)
+
+		tricks, err := im.GetSlice("tricks")
+		require.NoError(t, err)
+		assert.Equal(t, 6, tricks.Len())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+
+		_, err := im.GetString("missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+		var pathErr *PathError
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/missing", pathErr.Path)
+
+		_, err = im.GetString("age")
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = im.GetInt64("breed")
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = im.GetFloat64("vet")
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = im.GetBool("age")
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = im.GetMap("tricks")
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = im.GetSlice("owner")
+		assert.ErrorIs(t, err, ErrWrongType)
+		assert.EqualError(t, err, `green: path "/owner": wrong type: map is not a slice`)
+
+		_, err = im.GetInt64("weight")
+		assert.ErrorIs(t, err, ErrLossyConversion)
+		_, err = im.GetInt64("huge")
+		assert.ErrorIs(t, err, ErrLossyConversion)
+		_, err = im.GetFloat64("precise")
+		assert.ErrorIs(t, err, ErrLossyConversion)
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/precise", pathErr.Path)
+	})
+
+	t.Run("json.Number", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{
+			"int":       json.Number("9007199254740993"),
+			"fraction":  json.Number("9007199254740993.0"),
+			"exponent":  json.Number("1e3"),
+			"decimal":   json.Number("0.1"),
+			"half":      json.Number("2.5"),
+			"overflow":  json.Number("9223372036854775808"),
+			"imprecise": json.Number("9007199254740993.5"),
+			"inf":       json.Number("1e400"),
+		})
+
+		for key, expect := range map[string]int64{"int": 1<<53 + 1, "fraction": 1<<53 + 1, "exponent": 1000} {
+			i, err := im.GetInt64(key)
+			require.NoError(t, err, key)
+			assert.Equal(t, expect, i, key)
+		}
+		for _, key := range []string{"decimal", "half", "overflow", "imprecise", "inf"} {
+			_, err := im.GetInt64(key)
+			assert.ErrorIs(t, err, ErrLossyConversion, key)
+		}
+
+		for key, expect := range map[string]float64{"exponent": 1000, "decimal": 0.1, "half": 2.5, "overflow": 1 << 63} {
+			f, err := im.GetFloat64(key)
+			require.NoError(t, err, key)
+			assert.Equal(t, expect, f, key)
+		}
+		for _, key := range []string{"int", "fraction", "imprecise", "inf"} {
+			_, err := im.GetFloat64(key)
+			assert.ErrorIs(t, err, ErrLossyConversion, key)
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		mut := NewImmutableMap(newSource()).Mutable()
+		mut.Set("age", 7)
+
+		i, err := mut.GetInt64("age")
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), i)
+
+		owner, err := mut.GetMap("owner")
+		require.NoError(t, err)
+		owner.Set("name", "Alex")
+		name, err := mut.GetMap("owner")
+		require.NoError(t, err)
+		s, err := name.GetString("name")
+		require.NoError(t, err)
+		assert.Equal(t, "Alex", s)
+
+		tricks, err := mut.GetSlice("tricks")
+		require.NoError(t, err)
+		tricks.Push("speak")
+		s, err = tricks.AtString(6)
+		require.NoError(t, err)
+		assert.Equal(t, "speak", s)
+
+		_, err = mut.GetBool("missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		is := NewImmutableSlice(newSource()["tricks"].([]any))
+		for _, s := range []interface {
+			AtString(int) (string, error)
+			AtInt64(int) (int64, error)
+			AtFloat64(int) (float64, error)
+			AtBool(int) (bool, error)
+		}{is, is.Mutable()} {
+			str, err := s.AtString(0)
+			require.NoError(t, err)
+			assert.Equal(t, "sit", str)
+
+			i, err := s.AtInt64(1)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), i)
+
+			f, err := s.AtFloat64(2)
+			require.NoError(t, err)
+			assert.Equal(t, 2.5, f)
+
+			b, err := s.AtBool(3)
+			require.NoError(t, err)
+			assert.False(t, b)
+
+			_, err = s.AtInt64(2)
+			assert.ErrorIs(t, err, ErrLossyConversion)
+			_, err = s.AtString(6)
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = s.AtString(-1)
+			assert.ErrorIs(t, err, ErrNotFound)
+			var pathErr *PathError
+			require.True(t, errors.As(err, &pathErr))
+			assert.Equal(t, "/-1", pathErr.Path)
+		}
+
+		m, err := is.AtMap(4)
+		require.NoError(t, err)
+		assert.Equal(t, 0, m.Len())
+		sl, err := is.AtSlice(5)
+		require.NoError(t, err)
+		assert.Equal(t, 0, sl.Len())
+
+		mut := is.Mutable()
+		mm, err := mut.AtMap(4)
+		require.NoError(t, err)
+		mm.Set("a", 1)
+		assert.Equal(t, map[string]any{"a": 1}, mut.Export()[4])
+		_, err = mut.AtSlice(4)
+		assert.ErrorIs(t, err, ErrWrongType)
+	})
+}
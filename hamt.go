@@ -0,0 +1,373 @@
+package green
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// hamtBits is the number of hash bits consumed per level of the trie.
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+	// hamtMaxShift is the shift at which all hash bits have been consumed, so
+	// nodes hold colliding entries in a flat list.
+	hamtMaxShift = 64
+)
+
+var hamtSeed = maphash.MakeSeed()
+
+type (
+	// MapOption configures NewImmutableMap.
+	MapOption func(*mapConfig)
+
+	mapConfig struct {
+		hamt             bool
+		compactThreshold int
+	}
+)
+
+// WithHAMT makes NewImmutableMap store the map in a persistent hash array
+// mapped trie (HAMT) instead of wrapping it. Building the trie copies the
+// top level of the map, but ImmutableMaps canonized from Maps derived from it
+// are tries as well, which share all untouched nodes with the original. Thus
+// Mutable, a few calls to Set or Delete, and Immutable cost O(w*log(n)) rather
+// than growing the chain of inherited maps (see ChainDepth), where w is the
+// number of keys written and n is the number of key-value pairs in the map.
+// Lookups cost O(log(n)) regardless of how many versions preceded them.
+//
+// This suits very large maps which undergo frequent small edits. The option
+// only applies to the top level of the map; nested maps are wrapped as usual.
+func WithHAMT() MapOption {
+	return func(cfg *mapConfig) {
+		cfg.hamt = true
+	}
+}
+
+type (
+	// hamt is a persistent hash array mapped trie from strings to values.
+	// Updates return a new hamt which shares all untouched nodes with the
+	// original.
+	hamt struct {
+		root *hamtNode
+		size int
+	}
+
+	// hamtNode is a node of a hamt. Below hamtMaxShift, bitmap records which
+	// of the 32 slots for the next hamtBits of the hash are occupied, and
+	// entries holds the occupied slots in order. At hamtMaxShift, entries is
+	// an unordered list of entries with identical hashes.
+	hamtNode struct {
+		bitmap  uint32
+		entries []hamtEntry
+		// edit, if not nil, identifies the batch of updates which created the
+		// node, which may modify it in place.
+		edit *hamtEdit
+	}
+
+	// hamtEntry is either a key-value pair, or, if node is not nil, a
+	// pointer to the next level.
+	hamtEntry struct {
+		hash uint64
+		key  string
+		val  any
+		node *hamtNode
+	}
+
+	// hamtEdit identifies a batch of updates. See hamtNode.edit. It must not
+	// have size zero, since pointers to distinct zero-size values may be
+	// equal.
+	hamtEdit struct {
+		_ byte
+	}
+)
+
+func hamtHash(key string) uint64 {
+	return maphash.String(hamtSeed, key)
+}
+
+// newHAMT builds a hamt holding the entries of m, with values converted by f.
+func newHAMT(m map[string]any, f func(any) any) *hamt {
+	t := &hamt{}
+	edit := &hamtEdit{}
+	for k, v := range m {
+		t.setIn(edit, k, f(v))
+	}
+	return t
+}
+
+func (t *hamt) len() int {
+	if t == nil {
+		return 0
+	}
+	return t.size
+}
+
+func (t *hamt) get(key string) (any, bool) {
+	if t == nil {
+		return nil, false
+	}
+	return t.root.get(hamtHash(key), key)
+}
+
+func (n *hamtNode) get(hash uint64, key string) (any, bool) {
+	for shift := 0; n != nil; shift += hamtBits {
+		if shift >= hamtMaxShift {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.val, true
+				}
+			}
+			return nil, false
+		}
+		bit, i := n.slot(hash, shift)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+		e := n.entries[i]
+		if e.node == nil {
+			if e.key == key {
+				return e.val, true
+			}
+			return nil, false
+		}
+		n = e.node
+	}
+	return nil, false
+}
+
+// update returns a new hamt with the given writes applied. Values for which
+// isDeleted is true delete their keys.
+func (t *hamt) update(writes map[string]any) *hamt {
+	t2 := &hamt{}
+	if t != nil {
+		*t2 = *t
+	}
+	edit := &hamtEdit{}
+	for k, v := range writes {
+		if isDeleted(v) {
+			t2.deleteIn(edit, k)
+		} else {
+			t2.setIn(edit, k, v)
+		}
+	}
+	return t2
+}
+
+// setIn sets the key in place, copying the nodes along its path which do not
+// belong to the edit.
+func (t *hamt) setIn(edit *hamtEdit, key string, val any) {
+	var added bool
+	t.root, added = t.root.set(edit, hamtEntry{hash: hamtHash(key), key: key, val: val}, 0)
+	if added {
+		t.size++
+	}
+}
+
+// deleteIn is like setIn, but deletes the key.
+func (t *hamt) deleteIn(edit *hamtEdit, key string) {
+	var removed bool
+	t.root, removed = t.root.delete(edit, hamtHash(key), key, 0)
+	if removed {
+		t.size--
+	}
+}
+
+func (t *hamt) all() iter.Seq2[string, ImmutableValue] {
+	return func(yield func(string, ImmutableValue) bool) {
+		if t != nil {
+			t.root.all(yield)
+		}
+	}
+}
+
+func (n *hamtNode) slot(hash uint64, shift int) (bit uint32, index int) {
+	bit = 1 << ((hash >> shift) & hamtMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+// editable returns n, if it belongs to the edit, or a copy which does.
+func (n *hamtNode) editable(edit *hamtEdit) *hamtNode {
+	if n.edit == edit {
+		return n
+	}
+	return &hamtNode{bitmap: n.bitmap, entries: slices.Clone(n.entries), edit: edit}
+}
+
+func (n *hamtNode) set(edit *hamtEdit, leaf hamtEntry, shift int) (*hamtNode, bool) {
+	if n == nil {
+		n = &hamtNode{edit: edit}
+	}
+
+	if shift >= hamtMaxShift {
+		i := slices.IndexFunc(n.entries, func(e hamtEntry) bool { return e.key == leaf.key })
+		n = n.editable(edit)
+		if i >= 0 {
+			n.entries[i] = leaf
+			return n, false
+		}
+		n.entries = append(n.entries, leaf)
+		return n, true
+	}
+
+	bit, i := n.slot(leaf.hash, shift)
+	if n.bitmap&bit == 0 {
+		n = n.editable(edit)
+		n.bitmap |= bit
+		n.entries = slices.Insert(n.entries, i, leaf)
+		return n, true
+	}
+
+	e := n.entries[i]
+	switch {
+	case e.node != nil:
+		child, added := e.node.set(edit, leaf, shift+hamtBits)
+		if child == e.node {
+			return n, added
+		}
+		n = n.editable(edit)
+		n.entries[i] = hamtEntry{node: child}
+		return n, added
+	case e.key == leaf.key:
+		n = n.editable(edit)
+		n.entries[i] = leaf
+		return n, false
+	default:
+		// split the slot into a new level holding both entries
+		child, _ := (*hamtNode)(nil).set(edit, e, shift+hamtBits)
+		child, _ = child.set(edit, leaf, shift+hamtBits)
+		n = n.editable(edit)
+		n.entries[i] = hamtEntry{node: child}
+		return n, true
+	}
+}
+
+func (n *hamtNode) delete(edit *hamtEdit, hash uint64, key string, shift int) (*hamtNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if shift >= hamtMaxShift {
+		i := slices.IndexFunc(n.entries, func(e hamtEntry) bool { return e.key == key })
+		if i < 0 {
+			return n, false
+		}
+		if len(n.entries) == 1 {
+			return nil, true
+		}
+		n = n.editable(edit)
+		n.entries = slices.Delete(n.entries, i, i+1)
+		return n, true
+	}
+
+	bit, i := n.slot(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	e := n.entries[i]
+	if e.node == nil {
+		if e.key != key {
+			return n, false
+		}
+		if len(n.entries) == 1 {
+			return nil, true
+		}
+		n = n.editable(edit)
+		n.bitmap &^= bit
+		n.entries = slices.Delete(n.entries, i, i+1)
+		return n, true
+	}
+
+	child, removed := e.node.delete(edit, hash, key, shift+hamtBits)
+	if !removed {
+		return n, false
+	}
+	n = n.editable(edit)
+	switch {
+	case child == nil:
+		if len(n.entries) == 1 {
+			return nil, true
+		}
+		n.bitmap &^= bit
+		n.entries = slices.Delete(n.entries, i, i+1)
+	case len(child.entries) == 1 && child.entries[0].node == nil:
+		// pull a lone remaining entry up a level
+		n.entries[i] = child.entries[0]
+	default:
+		n.entries[i] = hamtEntry{node: child}
+	}
+	return n, true
+}
+
+func (n *hamtNode) all(yield func(string, ImmutableValue) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, e := range n.entries {
+		if e.node != nil {
+			if !e.node.all(yield) {
+				return false
+			}
+		} else if !yield(e.key, e.val) {
+			return false
+		}
+	}
+	return true
+}
+
+// hamtChangedKeys calls f with every key whose value may differ between the
+// tries rooted at a and b, skipping subtrees which they share.
+func hamtChangedKeys(a, b *hamtNode, shift int, f func(key string)) {
+	if a == b {
+		return
+	}
+	if a == nil || b == nil || shift >= hamtMaxShift {
+		a.keys(f)
+		b.keys(f)
+		return
+	}
+
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		aEntry, aOK := a.entryAt(bit)
+		bEntry, bOK := b.entryAt(bit)
+		switch {
+		case aOK && bOK && aEntry.node != nil && bEntry.node != nil:
+			hamtChangedKeys(aEntry.node, bEntry.node, shift+hamtBits, f)
+		case aOK && bOK && aEntry.node == nil && bEntry.node == nil && aEntry.key == bEntry.key:
+			f(aEntry.key)
+		default:
+			if aOK {
+				aEntry.keys(f)
+			}
+			if bOK {
+				bEntry.keys(f)
+			}
+		}
+	}
+}
+
+func (n *hamtNode) entryAt(bit uint32) (hamtEntry, bool) {
+	if n.bitmap&bit == 0 {
+		return hamtEntry{}, false
+	}
+	return n.entries[bits.OnesCount32(n.bitmap&(bit-1))], true
+}
+
+func (n *hamtNode) keys(f func(key string)) {
+	if n == nil {
+		return
+	}
+	for _, e := range n.entries {
+		e.keys(f)
+	}
+}
+
+func (e hamtEntry) keys(f func(key string)) {
+	if e.node != nil {
+		e.node.keys(f)
+	} else {
+		f(e.key)
+	}
+}
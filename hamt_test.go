@@ -0,0 +1,167 @@
+package green
+
+import (
+	"encoding/json"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHAMT(t *testing.T) {
+	t.Run("matches a native map across versions", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(1, 2))
+		want := make(map[string]any)
+		for i := range 2000 {
+			want["key"+strconv.Itoa(i)] = i
+		}
+
+		im := NewImmutableMap(maps.Clone(want), WithHAMT())
+		versions := []*ImmutableMap{im}
+		exports := []map[string]any{maps.Clone(want)}
+		for range 50 {
+			mut := versions[len(versions)-1].Mutable()
+			for range 20 {
+				k := "key" + strconv.Itoa(rng.IntN(3000))
+				if rng.IntN(3) == 0 {
+					mut.Delete(k)
+					delete(want, k)
+				} else {
+					mut.Set(k, k)
+					want[k] = k
+				}
+			}
+			im := mut.Immutable()
+			require.NotNil(t, im.trie)
+			assert.Equal(t, 0, im.ChainDepth())
+			versions = append(versions, im)
+			exports = append(exports, maps.Clone(want))
+		}
+
+		for i, im := range versions {
+			assert.Equal(t, len(exports[i]), im.Len())
+			assert.Equal(t, exports[i], im.Export())
+			for k, v := range exports[i] {
+				got, ok := im.Get(k)
+				require.True(t, ok)
+				require.Equal(t, v, got)
+			}
+			assert.False(t, im.Has("missing"))
+			assert.True(t, Equal(im, exports[i]))
+		}
+	})
+
+	t.Run("colliding hashes", func(t *testing.T) {
+		var root *hamtNode
+		edit := &hamtEdit{}
+		for _, k := range []string{"a", "b", "c"} {
+			var added bool
+			root, added = root.set(edit, hamtEntry{hash: 42, key: k, val: k}, 0)
+			assert.True(t, added)
+		}
+		for _, k := range []string{"a", "b", "c"} {
+			v, ok := root.get(42, k)
+			assert.True(t, ok)
+			assert.Equal(t, k, v)
+		}
+
+		root2, removed := root.delete(&hamtEdit{}, 42, "b", 0)
+		assert.True(t, removed)
+		root2, added := root2.set(&hamtEdit{}, hamtEntry{hash: 42, key: "c", val: "C"}, 0)
+		assert.False(t, added)
+		v, _ := root2.get(42, "c")
+		assert.Equal(t, "C", v)
+		_, ok := root2.get(42, "b")
+		assert.False(t, ok)
+
+		// the original is unchanged
+		v, _ = root.get(42, "c")
+		assert.Equal(t, "c", v)
+		_, ok = root.get(42, "b")
+		assert.True(t, ok)
+
+		root2, _ = root2.delete(&hamtEdit{}, 42, "a", 0)
+		root2, _ = root2.delete(&hamtEdit{}, 42, "c", 0)
+		assert.Nil(t, root2)
+	})
+
+	t.Run("structural sharing", func(t *testing.T) {
+		m := make(map[string]any)
+		for i := range 10000 {
+			m["key"+strconv.Itoa(i)] = i
+		}
+		m["owner"] = map[string]any{"name": "Sam"}
+		im := NewImmutableMap(m, WithHAMT())
+		owner, ok := im.Get("owner")
+		require.True(t, ok)
+
+		mut := im.Mutable()
+		mut.Set("key1", "one")
+		mut.Set("new", true)
+		mut.Delete("key2")
+		im2 := mut.Immutable()
+
+		var changed []string
+		hamtChangedKeys(im.trie.root, im2.trie.root, 0, func(k string) { changed = append(changed, k) })
+		assert.Less(t, len(changed), 200, "only the paths to written keys are copied")
+		assert.Subset(t, changed, []string{"key1", "new", "key2"})
+
+		owner2, ok := im2.Get("owner")
+		require.True(t, ok)
+		assert.Same(t, owner, owner2)
+
+		assert.Equal(t, []Change{
+			{Kind: ChangeReplaced, Path: "/key1", Old: 1, New: "one"},
+			{Kind: ChangeRemoved, Path: "/key2", Old: 2},
+			{Kind: ChangeAdded, Path: "/new", New: true},
+		}, Diff(im, im2))
+		assert.False(t, Equal(im, im2))
+		assert.True(t, Equal(im2, im2.Mutable().Immutable()))
+
+		mut = im2.Mutable()
+		mut.Set("key1", 1)
+		mut.Set("key2", 2)
+		mut.Delete("new")
+		assert.True(t, Equal(im, mut.Immutable()))
+		assert.True(t, Equal(im, NewImmutableMap(m)))
+		assert.Empty(t, Diff(im, mut.Immutable()))
+	})
+
+	t.Run("nested mutations", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{
+			"owner":  map[string]any{"name": "Sam"},
+			"tricks": []any{"sit"},
+		}, WithHAMT())
+		mut := im.Mutable()
+		require.NoError(t, mut.SetPath("/owner/name", "Alex"))
+		mustGetSliceFromMap(t, "tricks", mut).Push("shake")
+		im2 := mut.Immutable()
+
+		assert.Equal(t, map[string]any{
+			"owner":  map[string]any{"name": "Alex"},
+			"tricks": []any{"sit", "shake"},
+		}, im2.Export())
+		assert.Equal(t, map[string]any{
+			"owner":  map[string]any{"name": "Sam"},
+			"tricks": []any{"sit"},
+		}, im.Export())
+
+		b, err := json.Marshal(im2)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"owner":{"name":"Alex"},"tricks":["sit","shake"]}`, string(b))
+		assert.Equal(t, []string{"owner", "tricks"}, slices.Sorted(maps.Keys(im2.Export())))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		im := NewImmutableMap(nil, WithHAMT())
+		assert.Equal(t, 0, im.Len())
+		assert.Equal(t, map[string]any{}, im.Export())
+		mut := im.Mutable()
+		mut.Set("a", 1)
+		assert.Equal(t, map[string]any{"a": 1}, mut.Immutable().Export())
+	})
+}
@@ -1,5 +1,166 @@
 package green
 
+import (
+	"slices"
+	"sync"
+)
+
+// History records successive immutable snapshots of a value, such as those
+// canonized from a Map or Slice, and allows moving back and forth between
+// them. Since each snapshot canonized from a mutable shares all unmodified
+// nodes with the snapshot it was derived from, keeping many versions of a large
+// value costs memory proportional to the changes between versions only.
+//
+// Versions are numbered from 0 in the order they are recorded. Version numbers
+// remain stable when old versions are evicted due to the capacity, and are
+// never reused, even for versions discarded by Record.
+//
+// History methods are safe for concurrent use.
+type History struct {
+	mu       sync.Mutex
+	capacity int
+	versions []ImmutableValue
+	// numbers holds the version number of each of versions, in increasing
+	// order.
+	numbers []int
+	// next is the version number of the next version recorded.
+	next int
+	// current is the index in versions of the current version.
+	current int
+}
+
+// NewHistory returns an empty History which retains at most capacity versions,
+// evicting the oldest versions first. If capacity is not positive, the History
+// is unbounded.
+func NewHistory(capacity int) *History {
+	return &History{capacity: capacity, current: -1}
+}
+
+// Record records an immutable snapshot of v as the newest version and makes it
+// current. Mutable containers are canonized with Immutable first, and native Go
+// containers are wrapped. Any versions after the current version, i.e. those
+// that could be restored with Redo, are discarded, and their version numbers
+// are not handed out again. Recording the current container again does not
+// create a new version. The version number of the snapshot is returned.
+//
+// This has the time complexity of canonizing v, plus O(1) amortized.
+func (h *History) Record(v any) int {
+	iv := asImmutable(v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current >= 0 && sameContainer(h.versions[h.current], iv) {
+		return h.numbers[h.current]
+	}
+
+	clear(h.versions[h.current+1:])
+	h.versions = append(h.versions[:h.current+1], iv)
+	h.numbers = append(h.numbers[:h.current+1], h.next)
+	h.next++
+	h.current++
+	if h.capacity > 0 && len(h.versions) > h.capacity {
+		evict := len(h.versions) - h.capacity
+		clear(h.versions[:evict])
+		h.versions = h.versions[evict:]
+		h.numbers = h.numbers[evict:]
+		h.current -= evict
+	}
+	return h.numbers[h.current]
+}
+
+// Current returns the current version and its version number. If nothing has
+// been recorded, it returns (nil, -1).
+//
+// This has O(1) time complexity.
+func (h *History) Current() (ImmutableValue, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current < 0 {
+		return nil, -1
+	}
+	return h.versions[h.current], h.numbers[h.current]
+}
+
+// Undo makes the version before the current version current and returns it.
+// If there is no such version, it returns (nil, false) and the current version
+// is unchanged.
+//
+// This has O(1) time complexity.
+func (h *History) Undo() (ImmutableValue, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current <= 0 {
+		return nil, false
+	}
+	h.current--
+	return h.versions[h.current], true
+}
+
+// Redo makes the version after the current version current and returns it,
+// reverting the effect of Undo. If there is no such version, it returns
+// (nil, false) and the current version is unchanged.
+//
+// This has O(1) time complexity.
+func (h *History) Redo() (ImmutableValue, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current+1 >= len(h.versions) {
+		return nil, false
+	}
+	h.current++
+	return h.versions[h.current], true
+}
+
+// At returns the version with the given version number, without changing the
+// current version. If the version was evicted or never recorded, or was
+// discarded by Record, it returns (nil, false).
+//
+// This has O(log(n)) time complexity, where n is the number of retained
+// versions.
+func (h *History) At(version int) (ImmutableValue, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i, found := slices.BinarySearch(h.numbers, version)
+	if !found {
+		return nil, false
+	}
+	return h.versions[i], true
+}
+
+// Versions returns the version numbers of the oldest and newest retained
+// versions. Versions in between which were discarded by Record are not
+// retained. If nothing has been recorded, it returns (0, -1).
+//
+// This has O(1) time complexity.
+func (h *History) Versions() (oldest, newest int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.numbers) == 0 {
+		return 0, -1
+	}
+	return h.numbers[0], h.numbers[len(h.numbers)-1]
+}
+
+// sameContainer returns whether a and b are the same immutable container.
+func sameContainer(a, b ImmutableValue) bool {
+	switch a := a.(type) {
+	case *ImmutableMap:
+		b, ok := b.(*ImmutableMap)
+		return ok && a == b
+	case *ImmutableSlice:
+		b, ok := b.(*ImmutableSlice)
+		return ok && a == b
+	default:
+		return false
+	}
+}
+
 // import (
 // 	"iter"
 // )
@@ -0,0 +1,132 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	t.Run("Undo, Redo, At", func(t *testing.T) {
+		h := NewHistory(0)
+		v, version := h.Current()
+		assert.Nil(t, v)
+		assert.Equal(t, -1, version)
+		_, ok := h.Undo()
+		assert.False(t, ok)
+
+		mut := NewImmutableMap(map[string]any{"count": 0}).Mutable()
+		assert.Equal(t, 0, h.Record(mut))
+		assert.Equal(t, 0, h.Record(mut), "unchanged")
+		mut.Set("count", 1)
+		assert.Equal(t, 1, h.Record(mut))
+		mut.Set("count", 2)
+		assert.Equal(t, 2, h.Record(mut))
+
+		v, ok = h.Undo()
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 1}, v.(*ImmutableMap).Export())
+		v, ok = h.Undo()
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 0}, v.(*ImmutableMap).Export())
+		_, ok = h.Undo()
+		assert.False(t, ok)
+
+		v, ok = h.Redo()
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 1}, v.(*ImmutableMap).Export())
+		_, version = h.Current()
+		assert.Equal(t, 1, version)
+
+		v, ok = h.At(2)
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 2}, v.(*ImmutableMap).Export())
+		_, ok = h.At(3)
+		assert.False(t, ok)
+
+		// recording discards the versions that could be redone, without
+		// reusing their version numbers
+		mut = v.(*ImmutableMap).Mutable()
+		mut.Set("count", 3)
+		h.Undo()
+		assert.Equal(t, 3, h.Record(mut))
+		_, ok = h.Redo()
+		assert.False(t, ok)
+		for _, discarded := range []int{1, 2} {
+			_, ok = h.At(discarded)
+			assert.False(t, ok, discarded)
+		}
+		v, ok = h.At(3)
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 3}, v.(*ImmutableMap).Export())
+		oldest, newest := h.Versions()
+		assert.Equal(t, 0, oldest)
+		assert.Equal(t, 3, newest)
+
+		v, ok = h.Undo()
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"count": 0}, v.(*ImmutableMap).Export())
+		assert.Equal(t, 4, h.Record(NewImmutableMap(map[string]any{"count": "x"})))
+		_, ok = h.At(3)
+		assert.False(t, ok)
+		_, version = h.Current()
+		assert.Equal(t, 4, version)
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		h := NewHistory(3)
+		mut := NewImmutableSlice(nil).Mutable()
+		for i := range 10 {
+			mut.Push(i)
+			assert.Equal(t, i, h.Record(mut))
+		}
+
+		oldest, newest := h.Versions()
+		assert.Equal(t, 7, oldest)
+		assert.Equal(t, 9, newest)
+		_, ok := h.At(6)
+		assert.False(t, ok)
+		v, ok := h.At(7)
+		require.True(t, ok)
+		assert.Equal(t, 8, v.(*ImmutableSlice).Len())
+
+		h.Undo()
+		h.Undo()
+		_, ok = h.Undo()
+		assert.False(t, ok)
+		_, version := h.Current()
+		assert.Equal(t, 7, version)
+	})
+
+	t.Run("versions share structure", func(t *testing.T) {
+		source := make(map[string]any, 1000)
+		for i := range 1000 {
+			source[string(rune('a'+i%26))+string(rune(i))] = map[string]any{"i": i}
+		}
+		h := NewHistory(0)
+		im := NewImmutableMap(source)
+		h.Record(im)
+
+		mut := im.Mutable()
+		for i := range 1000 {
+			mut.Set("counter", i)
+			h.Record(mut)
+		}
+
+		first, ok := h.At(0)
+		require.True(t, ok)
+		last, _ := h.Current()
+		assert.Same(t, im, first)
+		assert.True(t, Equal(first, im))
+		counter, err := last.(*ImmutableMap).GetInt64("counter")
+		require.NoError(t, err)
+		assert.Equal(t, int64(999), counter)
+
+		// untouched nested values are the same instances in every version
+		key := string(rune('a')) + string(rune(0))
+		v1, _ := first.(*ImmutableMap).Get(key)
+		v2, _ := last.(*ImmutableMap).Get(key)
+		assert.Same(t, v1, v2)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"iter"
 	"sync"
+	"sync/atomic"
+	"weak"
 )
 
 type (
@@ -17,25 +19,63 @@ type (
 	//
 	// ImmutableMap methods are safe for concurrent use.
 	ImmutableMap struct {
-		inherited     *Map
-		base          map[string]any
+		inherited *Map
+		// depth is the length of the chain of inherited maps. See ChainDepth.
+		depth int
+		// compactThreshold is the depth from which maps derived from this one
+		// are based on compacted instead. See WithCompactThreshold.
+		compactThreshold int
+		// compacted caches the result of Compact.
+		compacted atomic.Pointer[ImmutableMap]
+		// trie, if not nil, holds the key-value pairs in place of inherited
+		// and base. See WithHAMT.
+		trie *hamt
+		// layers, if not nil, are the ImmutableMaps looked up in order in
+		// place of base, and layersLen their combined length once layersCount
+		// has run. See NewLayeredMap.
+		layers      []*ImmutableMap
+		layersLen   int
+		layersCount sync.Once
+		base        map[string]any
+		// raw, if not nil, holds the undecoded JSON of each value, in place of
+		// base. See NewImmutableMapFromJSON.
+		raw           map[string]json.RawMessage
 		subContainers map[string]ImmutableValue
 		mu            sync.Mutex
 		jsonBytes     []byte
 		jsonError     error
 		jsonMarshal   sync.Once
+		// jsonDone is set once jsonBytes and jsonError are populated.
+		jsonDone atomic.Bool
 	}
 
 	// ImmutableSlice provides a slice of values.
 	//
 	// ImmutableSlice methods are safe for concurrent use.
 	ImmutableSlice struct {
-		base          []any
+		// vec, if not nil, holds the elements in place of base. See
+		// WithVector.
+		vec *vector
+		// source, if not nil, is the ImmutableSlice which holds the elements
+		// of this one, from offset to offset+length, in place of base. See
+		// SubSlice.
+		source         *ImmutableSlice
+		offset, length int
+		// origin, if set, is the ImmutableSlice from which a Slice was derived
+		// and canonized into this one, if its elements kept their indexes,
+		// i.e. were only set or appended to. See Merge3.
+		origin weak.Pointer[ImmutableSlice]
+		base   []any
+		// raw, if not nil, holds the undecoded JSON of each element, in place
+		// of base.
+		raw           []json.RawMessage
 		subContainers map[int]ImmutableValue
 		mu            sync.Mutex
 		jsonBytes     []byte
 		jsonError     error
 		jsonMarshal   sync.Once
+		// jsonDone is set once jsonBytes and jsonError are populated.
+		jsonDone atomic.Bool
 	}
 )
 
@@ -44,9 +84,20 @@ type (
 // map should not be modified after being passed into this function. No
 // operations on the ImmutableMap modify the original map.
 //
-// This has O(1) time complexity.
-func NewImmutableMap(m map[string]any) *ImmutableMap {
-	return &ImmutableMap{base: m}
+// This has O(1) time complexity, or O(n) with the WithHAMT option, where n is
+// the number of key-value pairs in the map.
+func NewImmutableMap(m map[string]any, opts ...MapOption) *ImmutableMap {
+	var cfg mapConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.hamt {
+		return &ImmutableMap{trie: newHAMT(m, func(v any) any {
+			v, _ = isContainer(v)
+			return v
+		})}
+	}
+	return &ImmutableMap{base: m, compactThreshold: cfg.compactThreshold}
 }
 
 // NewImmutableSlice wraps a slice containing only native Go types and returns
@@ -54,8 +105,16 @@ func NewImmutableMap(m map[string]any) *ImmutableMap {
 // The slice should not be modified after being passed into this function. No
 // operations on the ImmutableSlice modify the original slice.
 //
-// This has O(1) time complexity.
-func NewImmutableSlice(s []any) *ImmutableSlice {
+// This has O(1) time complexity, or O(n) with the WithVector option, where n
+// is the number of elements in the slice.
+func NewImmutableSlice(s []any, opts ...SliceOption) *ImmutableSlice {
+	var cfg sliceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.vector {
+		return &ImmutableSlice{vec: newVector(s, asImmutable)}
+	}
 	return &ImmutableSlice{base: s}
 }
 
@@ -91,6 +150,14 @@ func (m *ImmutableMap) Get(key string) (ImmutableValue, bool) {
 		return nil, false
 	}
 
+	if m.trie != nil {
+		return m.trie.get(key)
+	}
+
+	if m.layers != nil {
+		return m.getLayered(key)
+	}
+
 	if m.inherited != nil {
 		return m.inherited.getRaw(key)
 	}
@@ -102,6 +169,10 @@ func (m *ImmutableMap) Get(key string) (ImmutableValue, bool) {
 		return v, true
 	}
 
+	if m.raw != nil {
+		return m.getLazy(key)
+	}
+
 	vBase, ok := m.base[key]
 	if !ok {
 		return nil, false
@@ -121,17 +192,33 @@ func (m *ImmutableMap) Get(key string) (ImmutableValue, bool) {
 // Has returns whether the ImmutableMap contains a value for the given key. If
 // the ImmutableMap is nil, this always returns false.
 //
-// This has O(1) time complexity.
+// This has O(1) time complexity, or O(l) for a layered map, where l is the
+// number of layers. See NewLayeredMap.
 func (m *ImmutableMap) Has(key string) bool {
 	if m == nil {
 		return false
 	}
 
+	if m.trie != nil {
+		_, ok := m.trie.get(key)
+		return ok
+	}
+
+	if m.layers != nil {
+		_, ok := m.getLayered(key)
+		return ok
+	}
+
 	if m.inherited != nil {
 		_, ok := m.inherited.getRaw(key)
 		return ok
 	}
 
+	if m.raw != nil {
+		_, ok := m.raw[key]
+		return ok
+	}
+
 	_, ok := m.base[key]
 	return ok
 }
@@ -139,16 +226,29 @@ func (m *ImmutableMap) Has(key string) bool {
 // Len returns the number of fields in the ImmutableMap. If the ImmutableMap is
 // nil, it returns 0.
 //
-// This has O(1) time complexity.
+// This has O(1) time complexity, except for the first call on a layered map.
+// See NewLayeredMap.
 func (m *ImmutableMap) Len() int {
 	if m == nil {
 		return 0
 	}
 
+	if m.trie != nil {
+		return m.trie.len()
+	}
+
+	if m.layers != nil {
+		return m.lenLayered()
+	}
+
 	if m.inherited != nil {
 		return m.inherited.Len()
 	}
 
+	if m.raw != nil {
+		return len(m.raw)
+	}
+
 	return len(m.base)
 }
 
@@ -160,7 +260,15 @@ func (m *ImmutableMap) Len() int {
 // This has O(k') average time complexity, where k' is the number of key-value
 // pairs in the map which get iterated over.
 func (m *ImmutableMap) All() iter.Seq2[string, ImmutableValue] {
-	if m.inherited != nil {
+	if m != nil && m.trie != nil {
+		return m.trie.all()
+	}
+
+	if m != nil && m.layers != nil {
+		return m.allLayered()
+	}
+
+	if m != nil && m.inherited != nil {
 		return m.inherited.allRaw()
 	}
 
@@ -169,6 +277,16 @@ func (m *ImmutableMap) All() iter.Seq2[string, ImmutableValue] {
 			return
 		}
 
+		if m.raw != nil {
+			for k := range m.raw {
+				v, _ := m.Get(k)
+				if !yield(k, v) {
+					return
+				}
+			}
+			return
+		}
+
 		for k := range m.base {
 			v, _ := m.Get(k)
 			if !yield(k, v) {
@@ -215,16 +333,30 @@ func (m *ImmutableMap) Equal(other any) bool {
 }
 
 func (m *ImmutableMap) MarshalJSON() ([]byte, error) {
+	return m.cacheJSON(func() ([]byte, error) {
+		return marshalJSON(m)
+	})
+}
+
+// cacheJSON returns the cached output of MarshalJSON, computing it with f if
+// it has not been computed yet.
+func (m *ImmutableMap) cacheJSON(f func() ([]byte, error)) ([]byte, error) {
 	m.jsonMarshal.Do(func() {
-		tmpMap := make(map[string]any, m.Len())
-		for k, v := range m.All() {
-			tmpMap[k] = v
-		}
-		m.jsonBytes, m.jsonError = json.Marshal(tmpMap)
+		m.jsonBytes, m.jsonError = f()
+		m.jsonDone.Store(true)
 	})
 	return m.jsonBytes, m.jsonError
 }
 
+// cachedJSON returns the cached output of MarshalJSON, if it has been
+// computed successfully, without computing it.
+func (m *ImmutableMap) cachedJSON() ([]byte, bool) {
+	if !m.jsonDone.Load() {
+		return nil, false
+	}
+	return m.jsonBytes, m.jsonError == nil
+}
+
 // At retrieves the ImmutableValue at the specified index. Like a native Go
 // slice, if the index is out of bounds, this function panics.
 //
@@ -242,6 +374,14 @@ func (s *ImmutableSlice) At(index int) ImmutableValue {
 		panic(fmt.Sprintf("*green.ImmutableSlice.At: index out of range [%d] with length %d", index, s.Len()))
 	}
 
+	if s.vec != nil {
+		return s.vec.get(index)
+	}
+
+	if s.source != nil {
+		return s.source.At(s.offset + index)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -249,6 +389,10 @@ func (s *ImmutableSlice) At(index int) ImmutableValue {
 		return v
 	}
 
+	if s.raw != nil {
+		return s.atLazy(index)
+	}
+
 	vBase := s.base[index]
 	v, ok := isContainer(vBase)
 	if ok {
@@ -270,6 +414,18 @@ func (s *ImmutableSlice) Len() int {
 		return 0
 	}
 
+	if s.vec != nil {
+		return s.vec.len()
+	}
+
+	if s.source != nil {
+		return s.length
+	}
+
+	if s.raw != nil {
+		return len(s.raw)
+	}
+
 	return len(s.base)
 }
 
@@ -278,7 +434,14 @@ func (s *ImmutableSlice) Len() int {
 // index (exclusive). Like a native Go slice, if the indexes are out of bounds,
 // or if left > right, this function panics.
 //
-// This has O(left-right) average time complexity.
+// The returned ImmutableSlice is a view which shares the elements of the
+// original, including nested containers which have already been wrapped, and
+// wraps the rest on access like the original does. Like a native Go
+// subslice, the view keeps all of the original's elements reachable.
+//
+// This has O(1) time complexity, or O(log(n)) if the ImmutableSlice is backed
+// by a vector (see WithVector), where n is the number of elements in the
+// slice.
 func (s *ImmutableSlice) SubSlice(left, right int) *ImmutableSlice {
 	if left < 0 {
 		panic(fmt.Sprintf("*green.ImmutableSlice.SubSlice: index out of range [%d]", left))
@@ -294,12 +457,16 @@ func (s *ImmutableSlice) SubSlice(left, right int) *ImmutableSlice {
 		return s
 	}
 
-	subBase := make([]any, right-left)
-	for i := 0; i < right-left; i++ {
-		subBase[i] = s.At(left + i)
+	if s.vec != nil {
+		return &ImmutableSlice{vec: s.vec.slice(left, right)}
 	}
 
-	return &ImmutableSlice{base: subBase}
+	if s.source != nil {
+		// view the source directly, so views of views don't chain
+		return &ImmutableSlice{source: s.source, offset: s.offset + left, length: right - left}
+	}
+
+	return &ImmutableSlice{source: s, offset: left, length: right - left}
 }
 
 // All returns an iterator over all elements in the ImmutableSlice in order.
@@ -315,7 +482,16 @@ func (s *ImmutableSlice) All() iter.Seq2[int, ImmutableValue] {
 			return
 		}
 
-		for i := range s.base {
+		if s.vec != nil {
+			for i, v := range s.vec.all() {
+				if !yield(i, v) {
+					return
+				}
+			}
+			return
+		}
+
+		for i := range s.Len() {
 			v := s.At(i)
 			if !yield(i, v) {
 				return
@@ -362,16 +538,30 @@ func (s *ImmutableSlice) Equal(other any) bool {
 }
 
 func (s *ImmutableSlice) MarshalJSON() ([]byte, error) {
+	return s.cacheJSON(func() ([]byte, error) {
+		return marshalJSON(s)
+	})
+}
+
+// cacheJSON returns the cached output of MarshalJSON, computing it with f if
+// it has not been computed yet.
+func (s *ImmutableSlice) cacheJSON(f func() ([]byte, error)) ([]byte, error) {
 	s.jsonMarshal.Do(func() {
-		tmpSlice := make([]any, s.Len())
-		for i, v := range s.All() {
-			tmpSlice[i] = v
-		}
-		s.jsonBytes, s.jsonError = json.Marshal(tmpSlice)
+		s.jsonBytes, s.jsonError = f()
+		s.jsonDone.Store(true)
 	})
 	return s.jsonBytes, s.jsonError
 }
 
+// cachedJSON returns the cached output of MarshalJSON, if it has been
+// computed successfully, without computing it.
+func (s *ImmutableSlice) cachedJSON() ([]byte, bool) {
+	if !s.jsonDone.Load() {
+		return nil, false
+	}
+	return s.jsonBytes, s.jsonError == nil
+}
+
 func isContainer(v any) (ImmutableValue, bool) {
 	switch vv := v.(type) {
 	case map[string]any:
@@ -384,3 +574,18 @@ func isContainer(v any) (ImmutableValue, bool) {
 		return vv, false
 	}
 }
+
+// asImmutable converts mutable and native Go containers into their immutable
+// counterparts. Other values, including immutable containers, are returned
+// as is.
+func asImmutable(v any) ImmutableValue {
+	switch v := v.(type) {
+	case *Map:
+		return v.Immutable()
+	case *Slice:
+		return v.Immutable()
+	default:
+		iv, _ := isContainer(v)
+		return iv
+	}
+}
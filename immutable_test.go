@@ -1,6 +1,7 @@
 package green
 
 import (
+	"encoding/json"
 	"maps"
 	"sync"
 	"testing"
@@ -302,6 +303,47 @@ func TestImmutable(t *testing.T) {
 		assert.Equal(t, expected, exported)
 	})
 
+	t.Run("ImmutableSlice_SubSlice_views", func(t *testing.T) {
+		src := []any{"zero", map[string]any{"a": 1}, []any{2, 3}, "three", map[string]any{"b": 4}}
+		ims := NewImmutableSlice(src)
+
+		// containers wrapped through a view are cached for the original too
+		sub := ims.SubSlice(1, 5)
+		subsub := sub.SubSlice(2, 4)
+		assert.Same(t, ims, subsub.source, "views of views share the original")
+		assert.Equal(t, 3, subsub.offset)
+		m := subsub.At(1)
+		assert.Same(t, m, ims.At(4))
+		assert.Same(t, m, sub.At(3))
+
+		assert.Equal(t, []any{"three", map[string]any{"b": 4}}, subsub.Export())
+		assert.True(t, Equal(subsub, []any{"three", map[string]any{"b": 4}}))
+		b, err := json.Marshal(sub)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"a":1},[2,3],"three",{"b":4}]`, string(b))
+
+		empty := sub.SubSlice(2, 2)
+		assert.Equal(t, 0, empty.Len())
+		assert.Equal(t, []any{}, empty.Export())
+		assert.Panics(t, func() { sub.SubSlice(0, 5) })
+		assert.Panics(t, func() { subsub.At(2) })
+
+		mut := sub.Mutable()
+		mut.Push("five")
+		mustGetMapFromSlice(t, 0, mut).Set("a", 10)
+		assert.Equal(t, []any{map[string]any{"a": 10}, []any{2, 3}, "three", map[string]any{"b": 4}, "five"}, mut.Immutable().Export())
+		assert.Equal(t, src, ims.Export())
+
+		// views of lazily decoded slices decode on access
+		im, err := NewImmutableMapFromJSON([]byte(`{"items":[1,{"a":2},[3]]}`))
+		require.NoError(t, err)
+		items, err := im.GetSlice("items")
+		require.NoError(t, err)
+		lazySub := items.SubSlice(1, 3)
+		assert.Same(t, items.At(1), lazySub.At(0))
+		assert.Equal(t, []any{map[string]any{"a": float64(2)}, []any{float64(3)}}, lazySub.Export())
+	})
+
 	t.Run("ImmutableSlice_concurrency_safety", func(t *testing.T) {
 		const numGoroutines = 100
 		s := []any{
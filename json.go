@@ -0,0 +1,199 @@
+package green
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"weak"
+)
+
+// ParseJSON decodes JSON data into an ImmutableValue. Objects are decoded into
+// *ImmutableMap, arrays into *ImmutableSlice, and all other values into the
+// same Go types as encoding/json uses when decoding into an any. If the data
+// is already compact JSON, the returned container reuses the given bytes as
+// the cached output of MarshalJSON.
+//
+// This has O(n) time complexity, where n is the length of the data.
+func ParseJSON(data []byte) (ImmutableValue, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		m := NewImmutableMap(v)
+		m.seedJSON(data)
+		return m, nil
+	case []any:
+		s := NewImmutableSlice(v)
+		s.seedJSON(data)
+		return s, nil
+	default:
+		return v, nil
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that ImmutableMaps can be used
+// directly as fields of types which are decoded from JSON. It replaces the
+// contents of the ImmutableMap, so it must not be called on an ImmutableMap
+// which may be in use elsewhere. If the data is already compact JSON, it is
+// reused as the cached output of MarshalJSON. Like encoding/json, decoding a
+// JSON null is a no-op.
+func (m *ImmutableMap) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+
+	var base map[string]any
+	if err := json.Unmarshal(data, &base); err != nil {
+		return err
+	}
+	m.reset(base)
+	m.seedJSON(data)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that ImmutableSlices can be
+// used directly as fields of types which are decoded from JSON. It replaces the
+// contents of the ImmutableSlice, so it must not be called on an
+// ImmutableSlice which may be in use elsewhere. If the data is already compact
+// JSON, it is reused as the cached output of MarshalJSON. Like encoding/json,
+// decoding a JSON null is a no-op.
+func (s *ImmutableSlice) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+
+	var base []any
+	if err := json.Unmarshal(data, &base); err != nil {
+		return err
+	}
+	s.reset(base)
+	s.seedJSON(data)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that Maps can be used directly
+// as fields of types which are decoded from JSON. It replaces the contents of
+// the Map; if the Map is nested in another container, the parent is marked
+// dirty. Like encoding/json, decoding a JSON null is a no-op.
+func (m *Map) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+
+	im := &ImmutableMap{}
+	if err := im.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	m.base = im
+	m.overwrites = nil
+	m.len = im.Len()
+	m.dirty = false
+	if len(m.parents) > 0 {
+		m.reportDirty()
+	}
+	if m.recording > 0 {
+		m.record(Mutation{Op: MutationReplace, Value: im}, nil)
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that Slices can be used
+// directly as fields of types which are decoded from JSON. It replaces the
+// contents of the Slice; if the Slice is nested in another container, the
+// parent is marked dirty. Like encoding/json, decoding a JSON null is a no-op.
+func (s *Slice) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+
+	is := &ImmutableSlice{}
+	if err := is.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	s.base = is
+	s.overwrites = nil
+	s.overwriteOffset = 0
+	s.prepends = nil
+	s.appends = nil
+	s.dirty = false
+	if len(s.parents) > 0 {
+		s.reportDirty()
+	}
+	if s.recording > 0 {
+		s.record(Mutation{Op: MutationReplace, Value: is}, nil)
+	}
+	return nil
+}
+
+func (m *ImmutableMap) reset(base map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inherited = nil
+	m.depth = 0
+	m.compacted.Store(nil)
+	m.trie = nil
+	m.layers = nil
+	m.layersLen = 0
+	m.layersCount = sync.Once{}
+	m.base = base
+	m.raw = nil
+	m.subContainers = nil
+	m.jsonBytes = nil
+	m.jsonError = nil
+	m.jsonMarshal = sync.Once{}
+	m.jsonDone.Store(false)
+}
+
+func (s *ImmutableSlice) reset(base []any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.vec = nil
+	s.source = nil
+	s.offset, s.length = 0, 0
+	s.origin = weak.Pointer[ImmutableSlice]{}
+	s.base = base
+	s.raw = nil
+	s.subContainers = nil
+	s.jsonBytes = nil
+	s.jsonError = nil
+	s.jsonMarshal = sync.Once{}
+	s.jsonDone.Store(false)
+}
+
+// seedJSON primes the MarshalJSON cache with data if it is compact, which is
+// the form MarshalJSON produces.
+func (m *ImmutableMap) seedJSON(data []byte) {
+	if !isCompactJSON(data) {
+		return
+	}
+	m.cacheJSON(func() ([]byte, error) {
+		return bytes.Clone(data), nil
+	})
+}
+
+func (s *ImmutableSlice) seedJSON(data []byte) {
+	if !isCompactJSON(data) {
+		return
+	}
+	s.cacheJSON(func() ([]byte, error) {
+		return bytes.Clone(data), nil
+	})
+}
+
+func isCompactJSON(data []byte) bool {
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	if err := json.Compact(&buf, data); err != nil {
+		return false
+	}
+	return bytes.Equal(buf.Bytes(), data)
+}
+
+func isJSONNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
@@ -0,0 +1,141 @@
+package green
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	t.Run("ParseJSON", func(t *testing.T) {
+		v, err := ParseJSON([]byte(`{"b":[1,{"c":true}],"a":null}`))
+		require.NoError(t, err)
+		im, ok := v.(*ImmutableMap)
+		require.True(t, ok, "%T", v)
+		assert.Equal(t, map[string]any{"b": []any{1.0, map[string]any{"c": true}}, "a": nil}, im.Export())
+
+		v, err = ParseJSON([]byte(`[1, "two"]`))
+		require.NoError(t, err)
+		is, ok := v.(*ImmutableSlice)
+		require.True(t, ok, "%T", v)
+		assert.Equal(t, []any{1.0, "two"}, is.Export())
+
+		v, err = ParseJSON([]byte(`"str"`))
+		require.NoError(t, err)
+		assert.Equal(t, "str", v)
+
+		v, err = ParseJSON([]byte(`null`))
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		_, err = ParseJSON([]byte(`{"a":`))
+		assert.Error(t, err)
+	})
+
+	t.Run("compact input seeds the MarshalJSON cache", func(t *testing.T) {
+		// keys are deliberately out of order; json.Marshal would sort them
+		data := []byte(`{"b":1,"a":[2,3]}`)
+		v, err := ParseJSON(data)
+		require.NoError(t, err)
+		got, err := json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, string(data), string(got))
+
+		// the cache does not alias the input
+		data[2] = 'x'
+		got, err = json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, `{"b":1,"a":[2,3]}`, string(got))
+
+		v, err = ParseJSON([]byte(`{"b": 1, "a": [2, 3]}`))
+		require.NoError(t, err)
+		got, err = json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":[2,3],"b":1}`, string(got))
+
+		v, err = ParseJSON([]byte(`[3,1,2]`))
+		require.NoError(t, err)
+		got, err = json.Marshal(v)
+		require.NoError(t, err)
+		assert.Equal(t, `[3,1,2]`, string(got))
+	})
+
+	t.Run("struct fields", func(t *testing.T) {
+		type request struct {
+			Name      string          `json:"name"`
+			Attrs     *ImmutableMap   `json:"attrs"`
+			Tags      *ImmutableSlice `json:"tags"`
+			Overrides *Map            `json:"overrides"`
+			Extra     *Slice          `json:"extra"`
+			Missing   *ImmutableMap   `json:"missing"`
+			Null      *ImmutableMap   `json:"null"`
+		}
+
+		data := `{
+			"name": "rex",
+			"attrs": {"breed": "Great Pyrenees", "tricks": ["sit"]},
+			"tags": ["good", "dog"],
+			"overrides": {"age": 6},
+			"extra": [{"k": "v"}],
+			"null": null
+		}`
+		var req request
+		require.NoError(t, json.Unmarshal([]byte(data), &req))
+
+		assert.Equal(t, "rex", req.Name)
+		assert.Equal(t, map[string]any{"breed": "Great Pyrenees", "tricks": []any{"sit"}}, req.Attrs.Export())
+		assert.Equal(t, []any{"good", "dog"}, req.Tags.Export())
+		assert.Equal(t, map[string]any{"age": 6.0}, req.Overrides.Export())
+		assert.Equal(t, []any{map[string]any{"k": "v"}}, req.Extra.Export())
+		assert.Nil(t, req.Missing)
+		assert.Nil(t, req.Null)
+
+		req.Overrides.Set("age", 7)
+		mustGetMapFromSlice(t, 0, req.Extra).Set("k", "w")
+
+		out, err := json.Marshal(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"name": "rex",
+			"attrs": {"breed": "Great Pyrenees", "tricks": ["sit"]},
+			"tags": ["good", "dog"],
+			"overrides": {"age": 7},
+			"extra": [{"k": "w"}],
+			"missing": null,
+			"null": null
+		}`, string(out))
+	})
+
+	t.Run("unmarshal replaces contents", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{"old": 1})
+		_, err := im.MarshalJSON()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(`{"new": 2}`), im))
+		assert.Equal(t, map[string]any{"new": 2.0}, im.Export())
+		got, err := im.MarshalJSON()
+		require.NoError(t, err)
+		assert.Equal(t, `{"new":2}`, string(got))
+
+		mut := NewImmutableMap(map[string]any{"old": 1}).Mutable()
+		mut.Set("other", 1)
+		require.NoError(t, json.Unmarshal([]byte(`{"new": 2}`), mut))
+		assert.Equal(t, map[string]any{"new": 2.0}, mut.Export())
+		assert.Equal(t, 1, mut.Len())
+
+		require.NoError(t, json.Unmarshal([]byte(`null`), mut))
+		assert.Equal(t, map[string]any{"new": 2.0}, mut.Export())
+
+		assert.Error(t, json.Unmarshal([]byte(`[1]`), mut))
+	})
+
+	t.Run("unmarshal into nested container marks parent dirty", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{"m": map[string]any{"k": 1}, "s": []any{1}})
+		mut := im.Mutable()
+		require.NoError(t, json.Unmarshal([]byte(`{"k":2}`), mustGetMapFromMap(t, "m", mut)))
+		require.NoError(t, json.Unmarshal([]byte(`[2,3]`), mustGetSliceFromMap(t, "s", mut)))
+		assert.Equal(t, map[string]any{"m": map[string]any{"k": 2.0}, "s": []any{2.0, 3.0}}, mut.Immutable().Export())
+		assert.Equal(t, map[string]any{"m": map[string]any{"k": 1}, "s": []any{1}}, im.Export())
+	})
+}
@@ -0,0 +1,150 @@
+package green
+
+import "iter"
+
+type tombstoneType struct{}
+
+// Tombstone marks a key as deleted in a layer of a map created with
+// NewLayeredMap, hiding any value for the key in the layers after it. It can be
+// stored as a value in any map which is used as a layer, such as
+// map[string]any{"debug": green.Tombstone}. Merge3 also uses it for absent
+// values in a Conflict. Elsewhere it has no special meaning.
+var Tombstone ImmutableValue = tombstoneType{}
+
+func isTombstone(v any) bool {
+	_, ok := v.(tombstoneType)
+	return ok
+}
+
+// NewLayeredMap returns an ImmutableMap which is a read-only view of the
+// layers stacked in order, so that earlier layers take precedence. Looking up
+// a key returns the value from the first layer which has the key, unless that
+// value is Tombstone, in which case the key is absent. Nested maps are not
+// merged across layers; the first hit wins as a whole. See Merge for a deep
+// merge. Nil layers are skipped. A layered map may itself be a layer, and its
+// tombstones keep hiding the values in the layers after it.
+//
+// The layers are shared, not copied, so creating the view is cheap, e.g. to
+// let per-request settings shadow global settings. Mutable and Compact work on
+// the view like on any other ImmutableMap.
+//
+// This has O(l) time complexity, where l is the number of layers. Get and Has
+// on the returned ImmutableMap have O(l) average time complexity. The first
+// call to Len has O(n) average time complexity, where n is the total number
+// of key-value pairs in the layers, and later calls have O(1) time complexity.
+func NewLayeredMap(layers ...*ImmutableMap) *ImmutableMap {
+	nonNil := make([]*ImmutableMap, 0, len(layers))
+	for _, l := range layers {
+		if l != nil {
+			nonNil = append(nonNil, l)
+		}
+	}
+	return &ImmutableMap{layers: nonNil}
+}
+
+func (m *ImmutableMap) getLayered(key string) (ImmutableValue, bool) {
+	v, ok := m.getLayer(key)
+	if !ok || isTombstone(v) {
+		return nil, false
+	}
+	return v, true
+}
+
+func (m *ImmutableMap) lenLayered() int {
+	m.layersCount.Do(func() {
+		for range m.allLayered() {
+			m.layersLen++
+		}
+	})
+	return m.layersLen
+}
+
+func (m *ImmutableMap) allLayered() iter.Seq2[string, ImmutableValue] {
+	return func(yield func(string, ImmutableValue) bool) {
+		for k, v := range m.allLayer() {
+			if isTombstone(v) {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// getLayer is like Get, but returns the Tombstone for a key which a layered
+// map hides with one, including through maps canonized from Maps derived from
+// a layered map. Thus, a layered map used as a layer of another keeps hiding
+// the values of later layers.
+func (m *ImmutableMap) getLayer(key string) (ImmutableValue, bool) {
+	switch {
+	case m == nil:
+		return nil, false
+	case m.layers != nil:
+		for _, l := range m.layers {
+			if v, ok := l.getLayer(key); ok {
+				return v, true
+			}
+		}
+		return nil, false
+	case m.inherited != nil:
+		if v, ok := m.inherited.overwrites[key]; ok {
+			if isDeleted(v) {
+				return nil, false
+			}
+			return v, true
+		}
+		return m.inherited.base.getLayer(key)
+	default:
+		return m.Get(key)
+	}
+}
+
+// allLayer is like All, but yields the Tombstones getLayer would return.
+func (m *ImmutableMap) allLayer() iter.Seq2[string, ImmutableValue] {
+	switch {
+	case m == nil:
+		return m.All()
+	case m.layers != nil:
+		return func(yield func(string, ImmutableValue) bool) {
+			seen := make(map[string]struct{})
+			for i, l := range m.layers {
+				for k, v := range l.allLayer() {
+					if i > 0 {
+						if _, ok := seen[k]; ok {
+							continue
+						}
+					}
+					if i < len(m.layers)-1 {
+						seen[k] = struct{}{}
+					}
+					if !yield(k, v) {
+						return
+					}
+				}
+			}
+		}
+	case m.inherited != nil:
+		return func(yield func(string, ImmutableValue) bool) {
+			overwrites := m.inherited.overwrites
+			for k, v := range overwrites {
+				if isDeleted(v) {
+					continue
+				}
+				if !yield(k, v) {
+					return
+				}
+			}
+			for k, v := range m.inherited.base.allLayer() {
+				if _, overwritten := overwrites[k]; overwritten {
+					continue
+				}
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	default:
+		return m.All()
+	}
+}
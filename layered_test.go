@@ -0,0 +1,137 @@
+package green
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayeredMap(t *testing.T) {
+	newGlobal := func() map[string]any {
+		return map[string]any{
+			"timeout": 30,
+			"debug":   false,
+			"server":  map[string]any{"port": 8080, "host": "localhost"},
+			"tags":    []any{"a"},
+		}
+	}
+
+	t.Run("first hit wins", func(t *testing.T) {
+		global := NewImmutableMap(newGlobal())
+		tenant := NewImmutableMap(map[string]any{"timeout": 10, "tags": Tombstone})
+		request := NewImmutableMap(map[string]any{"debug": true, "server": map[string]any{"port": 9090}}, WithHAMT())
+		layered := NewLayeredMap(request, nil, tenant, global)
+
+		v, ok := layered.Get("timeout")
+		assert.True(t, ok)
+		assert.Equal(t, 10, v)
+		v, ok = layered.Get("debug")
+		assert.True(t, ok)
+		assert.Equal(t, true, v)
+		v, ok = layered.Get("tags")
+		assert.False(t, ok)
+		assert.Nil(t, v)
+		assert.False(t, layered.Has("tags"))
+		assert.True(t, layered.Has("server"))
+		assert.False(t, layered.Has("missing"))
+
+		want := map[string]any{
+			"timeout": 10,
+			"debug":   true,
+			"server":  map[string]any{"port": 9090},
+		}
+		assert.Equal(t, 3, layered.Len())
+		assert.Equal(t, want, layered.Export())
+		assert.True(t, Equal(layered, want))
+		b, err := json.Marshal(layered)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"timeout":10,"debug":true,"server":{"port":9090}}`, string(b))
+
+		// the layers are shared
+		server, ok := request.Get("server")
+		require.True(t, ok)
+		server2, ok := layered.Get("server")
+		require.True(t, ok)
+		assert.Same(t, server, server2)
+		assert.Equal(t, newGlobal(), global.Export())
+	})
+
+	t.Run("nested layered maps keep their tombstones", func(t *testing.T) {
+		global := NewImmutableMap(newGlobal())
+		inner := NewLayeredMap(NewImmutableMap(map[string]any{"debug": Tombstone}))
+		layered := NewLayeredMap(NewLayeredMap(inner), global)
+		assert.False(t, layered.Has("debug"))
+		assert.Equal(t, 3, layered.Len())
+		assert.NotContains(t, layered.Export(), "debug")
+
+		// also through a map canonized from a Map derived from the inner one
+		mut := inner.Mutable()
+		mut.Set("timeout", 5)
+		derived := mut.Immutable()
+		layered = NewLayeredMap(derived, global)
+		_, ok := layered.Get("debug")
+		assert.False(t, ok)
+		assert.Equal(t, map[string]any{
+			"timeout": 5,
+			"server":  map[string]any{"port": 8080, "host": "localhost"},
+			"tags":    []any{"a"},
+		}, layered.Export())
+
+		// deleting from a layer uncovers the later layers
+		mut = derived.Mutable()
+		mut.Delete("timeout")
+		layered = NewLayeredMap(mut.Immutable(), global)
+		v, ok := layered.Get("timeout")
+		assert.True(t, ok)
+		assert.Equal(t, 30, v)
+		assert.Equal(t, 3, layered.Len())
+
+		var unbased Map
+		unbased.Set("debug", Tombstone)
+		layered = NewLayeredMap(unbased.Immutable(), global)
+		assert.False(t, layered.Has("debug"))
+		assert.Equal(t, 3, layered.Len())
+	})
+
+	t.Run("Mutable and Compact", func(t *testing.T) {
+		global := NewImmutableMap(newGlobal())
+		layered := NewLayeredMap(NewImmutableMap(map[string]any{"debug": Tombstone}), global)
+
+		mut := layered.Mutable()
+		assert.Equal(t, 3, mut.Len())
+		mut.Set("debug", true)
+		mut.Delete("tags")
+		im := mut.Immutable()
+		assert.Equal(t, map[string]any{
+			"timeout": 30,
+			"debug":   true,
+			"server":  map[string]any{"port": 8080, "host": "localhost"},
+		}, im.Export())
+		assert.False(t, layered.Has("debug"))
+
+		compacted := layered.Compact()
+		assert.Nil(t, compacted.layers)
+		assert.Equal(t, layered.Export(), compacted.Export())
+		assert.Same(t, global, global.Compact())
+	})
+
+	t.Run("empty and nil", func(t *testing.T) {
+		empty := NewLayeredMap()
+		assert.Equal(t, 0, empty.Len())
+		assert.Equal(t, map[string]any{}, empty.Export())
+
+		var nilMap *ImmutableMap
+		layered := NewLayeredMap(nilMap, NewImmutableMap(map[string]any{"a": Tombstone}))
+		assert.Equal(t, 0, layered.Len())
+		assert.False(t, layered.Has("a"))
+	})
+
+	t.Run("UnmarshalJSON resets layers", func(t *testing.T) {
+		layered := NewLayeredMap(NewImmutableMap(newGlobal()))
+		require.NoError(t, json.Unmarshal([]byte(`{"a":1}`), layered))
+		assert.Nil(t, layered.layers)
+		assert.Equal(t, 1, layered.Len())
+	})
+}
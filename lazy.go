@@ -0,0 +1,207 @@
+package green
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// NewImmutableMapFromJSON returns an ImmutableMap backed by the given JSON
+// object. The data is validated, and the top-level keys are indexed, but no
+// values are decoded up front. Instead, each value is decoded the first time
+// it is accessed, and nested objects and arrays are themselves lazily parsed.
+// Decoded values are cached, so each value is decoded at most once. Values are
+// decoded into the same Go types as encoding/json uses when decoding into an
+// any.
+//
+// MarshalJSON on the returned ImmutableMap, and on any Map derived from it
+// which has not been mutated, returns data as is. Thus, data should not be
+// modified after being passed into this function.
+//
+// This has O(n) time complexity, where n is the length of data, but only
+// allocates for the top-level keys.
+func NewImmutableMapFromJSON(data []byte) (*ImmutableMap, error) {
+	if !json.Valid(data) {
+		// let encoding/json produce a descriptive error
+		var raw json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, fmt.Errorf("green: %w: JSON value is not an object", ErrWrongType)
+	}
+
+	m := newLazyMap(trimmed)
+	m.cacheJSON(func() ([]byte, error) {
+		return data, nil
+	})
+	return m, nil
+}
+
+func newLazyMap(data []byte) *ImmutableMap {
+	return &ImmutableMap{raw: indexJSONObject(data)}
+}
+
+func newLazySlice(data []byte) *ImmutableSlice {
+	return &ImmutableSlice{raw: indexJSONArray(data)}
+}
+
+// getLazy decodes and caches the value for the given key. The caller must
+// hold m.mu.
+func (m *ImmutableMap) getLazy(key string) (ImmutableValue, bool) {
+	raw, ok := m.raw[key]
+	if !ok {
+		return nil, false
+	}
+
+	v := decodeLazy(raw)
+	if m.subContainers == nil {
+		m.subContainers = make(map[string]ImmutableValue)
+	}
+	m.subContainers[key] = v
+	return v, true
+}
+
+// atLazy decodes and caches the element at the given index. The caller must
+// hold s.mu.
+func (s *ImmutableSlice) atLazy(index int) ImmutableValue {
+	v := decodeLazy(s.raw[index])
+	if s.subContainers == nil {
+		s.subContainers = make(map[int]ImmutableValue)
+	}
+	s.subContainers[index] = v
+	return v
+}
+
+// decodeLazy decodes a valid JSON value. Objects and arrays are returned as
+// lazily-parsed containers whose MarshalJSON returns the raw bytes.
+func decodeLazy(raw json.RawMessage) ImmutableValue {
+	switch raw[0] {
+	case '{':
+		m := newLazyMap(raw)
+		m.cacheJSON(func() ([]byte, error) {
+			return raw, nil
+		})
+		return m
+	case '[':
+		s := newLazySlice(raw)
+		s.cacheJSON(func() ([]byte, error) {
+			return raw, nil
+		})
+		return s
+	default:
+		var v any
+		// the data was validated up front, so this can't fail
+		_ = json.Unmarshal(raw, &v)
+		return v
+	}
+}
+
+// indexJSONObject returns the raw value of each member of the valid JSON
+// object in data, without copying or decoding the values. Like encoding/json,
+// the last of any duplicate keys wins.
+func indexJSONObject(data []byte) map[string]json.RawMessage {
+	index := make(map[string]json.RawMessage)
+	i := skipJSONSpace(data, 1)
+	for data[i] != '}' {
+		keyEnd := skipJSONString(data, i)
+		key := decodeJSONString(data[i:keyEnd])
+		i = skipJSONSpace(data, keyEnd)
+		i = skipJSONSpace(data, i+1) // ':'
+		valueEnd := skipJSONValue(data, i)
+		index[key] = data[i:valueEnd:valueEnd]
+		i = skipJSONSpace(data, valueEnd)
+		if data[i] == ',' {
+			i = skipJSONSpace(data, i+1)
+		}
+	}
+	return index
+}
+
+// indexJSONArray returns the raw value of each element of the valid JSON
+// array in data, without copying or decoding the values.
+func indexJSONArray(data []byte) []json.RawMessage {
+	index := make([]json.RawMessage, 0)
+	i := skipJSONSpace(data, 1)
+	for data[i] != ']' {
+		valueEnd := skipJSONValue(data, i)
+		index = append(index, data[i:valueEnd:valueEnd])
+		i = skipJSONSpace(data, valueEnd)
+		if data[i] == ',' {
+			i = skipJSONSpace(data, i+1)
+		}
+	}
+	return index
+}
+
+// skipJSONValue returns the index just past the valid JSON value starting at
+// data[i].
+func skipJSONValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return skipJSONString(data, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = skipJSONString(data, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	default:
+		// literals and numbers end at the next delimiter
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return i
+			}
+		}
+		return i
+	}
+}
+
+// skipJSONString returns the index just past the valid JSON string starting
+// at data[i].
+func skipJSONString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func decodeJSONString(quoted []byte) string {
+	if bytes.IndexByte(quoted, '\\') < 0 {
+		return string(quoted[1 : len(quoted)-1])
+	}
+	var s string
+	_ = json.Unmarshal(quoted, &s)
+	return s
+}
@@ -0,0 +1,138 @@
+package green
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazy(t *testing.T) {
+	const doc = ` {
+		"breed": "Great Pyrenees",
+		"age": 6,
+		"good": true,
+		"owner": null,
+		"tricks": ["sit", {"name": "shake", "level": [1, 2]}, [], "a\"b"],
+		"vet": {"name": "Dr. Who", "address": {"city": "Bern"}},
+		"escaped\"key": "x",
+		"dup": 1,
+		"dup": 2,
+		"empty": {}
+	} `
+
+	t.Run("decodes like encoding/json", func(t *testing.T) {
+		im, err := NewImmutableMapFromJSON([]byte(doc))
+		require.NoError(t, err)
+
+		var expect map[string]any
+		require.NoError(t, json.Unmarshal([]byte(doc), &expect))
+		assert.Equal(t, len(expect), im.Len())
+		assert.Equal(t, expect, im.Export())
+		assert.True(t, im.Has("escaped\"key"))
+		assert.False(t, im.Has("missing"))
+
+		dup, ok := im.Get("dup")
+		require.True(t, ok)
+		assert.Equal(t, 2.0, dup)
+
+		v, err := im.GetPath("/tricks/1/level/1")
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, v)
+	})
+
+	t.Run("values are decoded on first access", func(t *testing.T) {
+		im, err := NewImmutableMapFromJSON([]byte(doc))
+		require.NoError(t, err)
+		assert.Empty(t, im.subContainers)
+
+		vet, ok := im.Get("vet")
+		require.True(t, ok)
+		assert.Len(t, im.subContainers, 1)
+		vetMap, ok := vet.(*ImmutableMap)
+		require.True(t, ok, "%T", vet)
+		assert.NotNil(t, vetMap.raw)
+		assert.Empty(t, vetMap.subContainers)
+
+		vetAgain, ok := im.Get("vet")
+		require.True(t, ok)
+		assert.Same(t, vet, vetAgain)
+
+		tricks, ok := im.Get("tricks")
+		require.True(t, ok)
+		tricksSlice, ok := tricks.(*ImmutableSlice)
+		require.True(t, ok, "%T", tricks)
+		assert.Equal(t, 4, tricksSlice.Len())
+		assert.Empty(t, tricksSlice.subContainers)
+		assert.Equal(t, "a\"b", tricksSlice.At(3))
+		assert.Len(t, tricksSlice.subContainers, 1)
+		assert.Same(t, tricksSlice.At(1), tricksSlice.At(1))
+	})
+
+	t.Run("MarshalJSON returns the original bytes", func(t *testing.T) {
+		data := []byte(doc)
+		im, err := NewImmutableMapFromJSON(data)
+		require.NoError(t, err)
+
+		got, err := im.MarshalJSON()
+		require.NoError(t, err)
+		assert.Equal(t, doc, string(got))
+
+		mut := im.Mutable()
+		mustGetMapFromMap(t, "vet", mut) // wrapping does not dirty the Map
+		got, err = mut.MarshalJSON()
+		require.NoError(t, err)
+		assert.Equal(t, doc, string(got))
+
+		vet, _ := im.Get("vet")
+		got, err = json.Marshal(vet)
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"Dr. Who","address":{"city":"Bern"}}`, string(got))
+
+		require.NoError(t, mut.SetPath("/vet/name", "Dr. No"))
+		got, err = mut.MarshalJSON()
+		require.NoError(t, err)
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(got, &decoded))
+		assert.Equal(t, "Dr. No", decoded["vet"].(map[string]any)["name"])
+
+		got, err = mut.Immutable().MarshalJSON()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(got, &decoded))
+		assert.Equal(t, "Dr. No", decoded["vet"].(map[string]any)["name"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := NewImmutableMapFromJSON([]byte(`{"a": `))
+		assert.Error(t, err)
+		_, err = NewImmutableMapFromJSON([]byte(`[1]`))
+		assert.ErrorIs(t, err, ErrWrongType)
+		_, err = NewImmutableMapFromJSON([]byte(`"a"`))
+		assert.ErrorIs(t, err, ErrWrongType)
+	})
+
+	t.Run("concurrency safety", func(t *testing.T) {
+		im, err := NewImmutableMapFromJSON([]byte(doc))
+		require.NoError(t, err)
+
+		const numGoroutines = 50
+		found := make([]ImmutableValue, numGoroutines)
+		var wg sync.WaitGroup
+		for i := range numGoroutines {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := im.GetPath("/tricks/1")
+				assert.NoError(t, err)
+				found[i] = v
+				_ = im.Export()
+			}()
+		}
+		wg.Wait()
+		for i := 1; i < numGoroutines; i++ {
+			assert.Same(t, found[0], found[i])
+		}
+	})
+}
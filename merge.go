@@ -0,0 +1,229 @@
+package green
+
+import "fmt"
+
+// SliceStrategy determines how Merge combines a slice in an overlay with a
+// slice at the same path in the value it is merged onto.
+type SliceStrategy int
+
+const (
+	// SliceReplace replaces the slice with the overlay's slice. This is the
+	// default.
+	SliceReplace SliceStrategy = iota
+	// SliceAppend appends the elements of the overlay's slice to the slice.
+	SliceAppend
+	// SliceMergeByIndex merges each element of the overlay's slice into the
+	// element at the same index, and appends the elements past the end of
+	// the slice.
+	SliceMergeByIndex
+	// SliceMergeByKey merges each map in the overlay's slice into the first
+	// map in the slice with a matching value for the key field set by
+	// WithMergeKey, and appends the elements which match none.
+	SliceMergeByKey
+)
+
+type (
+	// MergeOption configures Merge. MergeOptions are passed to Merge among the
+	// overlays.
+	MergeOption func(*mergeConfig)
+
+	mergeConfig struct {
+		strategy SliceStrategy
+		key      string
+	}
+)
+
+// WithSliceStrategy sets how Merge combines slices. See SliceStrategy.
+func WithSliceStrategy(strategy SliceStrategy) MergeOption {
+	return func(cfg *mergeConfig) {
+		cfg.strategy = strategy
+	}
+}
+
+// WithMergeKey makes Merge combine slices of maps by matching the values of the
+// given key field, with the SliceMergeByKey strategy.
+func WithMergeKey(field string) MergeOption {
+	return func(cfg *mergeConfig) {
+		cfg.strategy = SliceMergeByKey
+		cfg.key = field
+	}
+}
+
+// Merge returns a new ImmutableMap holding the deep merge of the overlays onto
+// base, in order, so that later overlays take precedence. Maps are merged
+// recursively; slices are combined according to the SliceStrategy, which is
+// SliceReplace unless set with WithSliceStrategy or WithMergeKey; and any
+// other value in an overlay replaces the value at the same path. A nil value
+// in an overlay sets the value to nil rather than deleting it.
+//
+// Overlays may be a *ImmutableMap, *Map, or map[string]any, or nil, which is
+// skipped. Arguments of type MergeOption configure the merge instead of being
+// merged, regardless of their position. If any other overlay is not a map,
+// this panics. If base is nil, the overlays are merged onto an empty map.
+//
+// Neither base nor the overlays are modified. Subtrees which no overlay
+// touches keep sharing base, and values taken from overlays are shared rather
+// than copied. If base is backed by a HAMT (see WithHAMT), so is the result.
+//
+// This has O(p) average time complexity, where p is the total number of nodes
+// in the overlays, plus, for each pair of slices combined with SliceMergeByKey,
+// O(n) if their keys are strings, or O(n*m) otherwise, where n and m are the
+// numbers of elements in the slices.
+func Merge(base *ImmutableMap, overlays ...any) *ImmutableMap {
+	var cfg mergeConfig
+	maps := make([]*ImmutableMap, 0, len(overlays))
+	for i, o := range overlays {
+		switch o := o.(type) {
+		case nil:
+		case MergeOption:
+			o(&cfg)
+		default:
+			m, ok := asImmutable(o).(*ImmutableMap)
+			if !ok {
+				panic(fmt.Sprintf("green.Merge: overlay %d must be a map, got %s", i, describeType(o)))
+			}
+			maps = append(maps, m)
+		}
+	}
+
+	if base == nil {
+		base = NewImmutableMap(nil)
+	}
+	for _, m := range maps {
+		base = cfg.mergeMaps(base, m)
+	}
+	return base
+}
+
+func (cfg *mergeConfig) mergeMaps(a, b *ImmutableMap) *ImmutableMap {
+	if a == b {
+		return a
+	}
+
+	mut := a.Mutable()
+	for k, bv := range b.All() {
+		av, ok := a.Get(k)
+		if !ok {
+			mut.Set(k, bv)
+			continue
+		}
+		if v := cfg.mergeValues(av, bv); !sameContainer(v, av) {
+			mut.Set(k, v)
+		}
+	}
+	return mut.Immutable()
+}
+
+func (cfg *mergeConfig) mergeValues(a, b ImmutableValue) ImmutableValue {
+	switch a := a.(type) {
+	case *ImmutableMap:
+		if b, ok := b.(*ImmutableMap); ok {
+			return cfg.mergeMaps(a, b)
+		}
+	case *ImmutableSlice:
+		if b, ok := b.(*ImmutableSlice); ok {
+			return cfg.mergeSlices(a, b)
+		}
+	}
+	return b
+}
+
+func (cfg *mergeConfig) mergeSlices(a, b *ImmutableSlice) *ImmutableSlice {
+	if a == b && cfg.strategy != SliceAppend {
+		return a
+	}
+
+	switch cfg.strategy {
+	case SliceAppend:
+		mut := a.Mutable()
+		for _, v := range b.All() {
+			mut.Push(v)
+		}
+		return mut.Immutable()
+	case SliceMergeByIndex:
+		mut := a.Mutable()
+		for i, bv := range b.All() {
+			if i >= a.Len() {
+				mut.Push(bv)
+				continue
+			}
+			av := a.At(i)
+			if v := cfg.mergeValues(av, bv); !sameContainer(v, av) {
+				mut.Set(i, v)
+			}
+		}
+		return mut.Immutable()
+	case SliceMergeByKey:
+		// index the string keys, the common case, and scan for others
+		index := make(map[string]int)
+		for i, av := range a.All() {
+			if key, ok := mergeKey(av, cfg.key); ok {
+				if key, ok := key.(string); ok {
+					if _, dup := index[key]; !dup {
+						index[key] = i
+					}
+				}
+			}
+		}
+		find := func(key ImmutableValue) int {
+			if key, ok := key.(string); ok {
+				if i, ok := index[key]; ok {
+					return i
+				}
+				return -1
+			}
+			return a.IndexFunc(func(av ImmutableValue) bool {
+				aKey, ok := mergeKey(av, cfg.key)
+				return ok && sameMergeKey(aKey, key)
+			})
+		}
+
+		mut := a.Mutable()
+		// elements already merged into, in case several elements of b match
+		// the same element of a
+		merged := make(map[int]ImmutableValue)
+		for _, bv := range b.All() {
+			key, ok := mergeKey(bv, cfg.key)
+			i := -1
+			if ok {
+				i = find(key)
+			}
+			if i < 0 {
+				mut.Push(bv)
+				continue
+			}
+			av, ok := merged[i]
+			if !ok {
+				av = a.At(i)
+			}
+			if v := cfg.mergeValues(av, bv); !sameContainer(v, av) {
+				mut.Set(i, v)
+				merged[i] = v
+			}
+		}
+		return mut.Immutable()
+	default:
+		return b
+	}
+}
+
+// mergeKey returns the value of the key field of v, if v is a map which has
+// it.
+func mergeKey(v ImmutableValue, field string) (ImmutableValue, bool) {
+	m, ok := v.(*ImmutableMap)
+	if !ok {
+		return nil, false
+	}
+	return m.Get(field)
+}
+
+// sameMergeKey reports whether the key values match. Numbers match if they
+// are equal regardless of their Go types, since maps decoded from JSON hold
+// float64s.
+func sameMergeKey(a, b ImmutableValue) bool {
+	if aNum, ok := numberAsFloat64(a); ok {
+		bNum, ok := numberAsFloat64(b)
+		return ok && aNum == bNum
+	}
+	return Equal(a, b)
+}
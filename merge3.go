@@ -0,0 +1,227 @@
+package green
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Conflict describes a value which ours and theirs both changed, in different
+// ways, in a three-way merge by Merge3.
+type Conflict struct {
+	// Path is the JSON Pointer (RFC 6901) of the value.
+	Path string
+	// Base, Ours, and Theirs are the values at Path in base, ours, and theirs.
+	// A value which is absent is Tombstone.
+	Base, Ours, Theirs ImmutableValue
+}
+
+type (
+	// ConflictResolver decides the merged value of a Conflict found by Merge3.
+	// It returns the value and true if it resolved the conflict, or false to
+	// leave it unresolved. Returning Tombstone removes the value. The value may
+	// be immutable, mutable, or native Go.
+	ConflictResolver func(c Conflict) (any, bool)
+
+	// Merge3Option configures Merge3.
+	Merge3Option func(*merge3Config)
+
+	merge3Config struct {
+		resolve   ConflictResolver
+		conflicts []Conflict
+	}
+)
+
+// WithConflictResolver sets a ConflictResolver which Merge3 calls for each
+// conflict it finds.
+func WithConflictResolver(resolve ConflictResolver) Merge3Option {
+	return func(cfg *merge3Config) {
+		cfg.resolve = resolve
+	}
+}
+
+// Merge3 reconciles ours and theirs, two ImmutableMaps derived independently
+// from base, e.g. by mutating Maps derived from it and canonizing them. It
+// returns an ImmutableMap holding the changes from base made by either side,
+// and the conflicts which could not be merged automatically, ordered by path.
+//
+// Maps are merged key by key, so edits to different keys, or to different
+// fields of nested maps, never conflict. Slices which both sides only set
+// elements of and appended to, through Slices derived from the base slice, are
+// merged index by index over the elements of the base slice, and elements
+// appended by both sides are kept, ours first. Slices changed in any other way
+// on either side, e.g. with PushFront, Insert, or Remove, are merged as a
+// whole, since their elements may have moved. A change made identically on
+// both sides is not a conflict. Any other value changed on both sides, e.g. a
+// slice prepended to on one side and changed on the other, or a key deleted on
+// one side and set on the other, is a Conflict. Conflicts are passed to the resolver set with
+// WithConflictResolver, if any; those it leaves unresolved keep ours and are
+// returned.
+//
+// Neither base, ours, nor theirs are modified, and unchanged subtrees are
+// shared. If base is nil, it is treated as an empty map, and so are ours and
+// theirs.
+//
+// Like Diff, Merge3 uses pointer equality with base to skip the subtrees which
+// a side didn't change, and only visits the keys written on each side if they
+// were canonized from Maps derived from base. Thus, merging a few edits on
+// each side costs O(k) rather than O(n), where k is the number of nodes on
+// dirty paths. As with Diff, Compact drops this fast path for a side which is
+// related to base only through its result. This has O(n) time complexity in
+// the worst case, where n is the number of nodes in the graphs of base, ours,
+// and theirs.
+func Merge3(base, ours, theirs *ImmutableMap, opts ...Merge3Option) (*ImmutableMap, []Conflict) {
+	var cfg merge3Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	empty := NewImmutableMap(nil)
+	if base == nil {
+		base = empty
+	}
+	if ours == nil {
+		ours = empty
+	}
+	if theirs == nil {
+		theirs = empty
+	}
+
+	merged := cfg.mergeMaps(nil, base, ours, theirs)
+	return merged, cfg.conflicts
+}
+
+func (cfg *merge3Config) mergeValues(path []string, b, o, t ImmutableValue) ImmutableValue {
+	switch {
+	case sameContainer(o, t), sameContainer(b, t):
+		return o
+	case sameContainer(b, o):
+		return t
+	}
+
+	switch b := b.(type) {
+	case *ImmutableMap:
+		o, oOK := o.(*ImmutableMap)
+		t, tOK := t.(*ImmutableMap)
+		if oOK && tOK {
+			return cfg.mergeMaps(path, b, o, t)
+		}
+	case *ImmutableSlice:
+		o, oOK := o.(*ImmutableSlice)
+		t, tOK := t.(*ImmutableSlice)
+		if oOK && tOK {
+			if v, ok := cfg.mergeSlices(path, b, o, t); ok {
+				return v
+			}
+		}
+	}
+
+	switch {
+	case Equal(b, t), Equal(o, t):
+		return o
+	case Equal(b, o):
+		return t
+	}
+	return cfg.conflict(path, b, o, t)
+}
+
+func (cfg *merge3Config) mergeMaps(path []string, b, o, t *ImmutableMap) *ImmutableMap {
+	// only keys which either side may have changed need merging
+	keys := append(diffKeys(b, o), diffKeys(b, t)...)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	mut := o.Mutable()
+	for _, k := range keys {
+		oValue := getOrTombstone(o, k)
+		v := cfg.mergeValues(append(path, k), getOrTombstone(b, k), oValue, getOrTombstone(t, k))
+		switch {
+		case sameMergeResult(v, oValue):
+		case isTombstone(v):
+			mut.Delete(k)
+		default:
+			mut.Set(k, v)
+		}
+	}
+	return mut.Immutable()
+}
+
+// mergeSlices merges slices whose elements are at the same indexes as in the
+// base slice, and returns false for others.
+func (cfg *merge3Config) mergeSlices(path []string, b, o, t *ImmutableSlice) (*ImmutableSlice, bool) {
+	n := b.Len()
+	if o.Len() < n || t.Len() < n || !alignedWith(o, b) || !alignedWith(t, b) {
+		return nil, false
+	}
+
+	mut := o.Mutable()
+	var removed []int
+	for i := range n {
+		oValue := o.At(i)
+		v := cfg.mergeValues(append(path, strconv.Itoa(i)), b.At(i), oValue, t.At(i))
+		switch {
+		case sameMergeResult(v, oValue):
+		case isTombstone(v):
+			removed = append(removed, i)
+		default:
+			mut.Set(i, v)
+		}
+	}
+
+	// keep the elements appended by theirs, unless ours appended the same
+	if theirsAppended := t.SubSlice(n, t.Len()); !Equal(o.SubSlice(n, o.Len()), theirsAppended) {
+		for _, v := range theirsAppended.All() {
+			mut.Push(v)
+		}
+	}
+
+	for _, i := range slices.Backward(removed) {
+		mut.Remove(i)
+	}
+	return mut.Immutable(), true
+}
+
+// conflict resolves a conflict with the resolver, or records it and keeps
+// ours.
+func (cfg *merge3Config) conflict(path []string, b, o, t ImmutableValue) ImmutableValue {
+	c := Conflict{Path: formatPointer(path), Base: b, Ours: o, Theirs: t}
+	if cfg.resolve != nil {
+		if v, ok := cfg.resolve(c); ok {
+			return asImmutable(v)
+		}
+	}
+	cfg.conflicts = append(cfg.conflicts, c)
+	return o
+}
+
+// getOrTombstone returns the value of the key in m, or Tombstone if m doesn't
+// have it.
+func getOrTombstone(m *ImmutableMap, key string) ImmutableValue {
+	v, ok := m.Get(key)
+	if !ok {
+		return Tombstone
+	}
+	return v
+}
+
+// sameMergeResult reports whether the merged value v is the same as the
+// existing value old, so that old can be kept.
+func sameMergeResult(v, old ImmutableValue) bool {
+	switch v.(type) {
+	case *ImmutableMap, *ImmutableSlice:
+		return sameContainer(v, old)
+	default:
+		return Equal(v, old)
+	}
+}
+
+// alignedWith reports whether s was derived from base, through canonizing
+// Slices, by only setting and appending elements, so that the elements of
+// base are at the same indexes in s.
+func alignedWith(s, base *ImmutableSlice) bool {
+	for s != base {
+		if s = s.origin.Value(); s == nil {
+			return false
+		}
+	}
+	return true
+}
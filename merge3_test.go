@@ -0,0 +1,204 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3(t *testing.T) {
+	newBase := func() map[string]any {
+		return map[string]any{
+			"name":    "api",
+			"server":  map[string]any{"port": 8080, "host": "localhost"},
+			"tags":    []any{"a", "b"},
+			"limits":  map[string]any{"rps": 100},
+			"retries": 3,
+		}
+	}
+
+	t.Run("non-overlapping edits", func(t *testing.T) {
+		base := NewImmutableMap(newBase())
+		limits, ok := base.Get("limits")
+		require.True(t, ok)
+
+		mut := base.Mutable()
+		mustGetMapFromMap(t, "server", mut).Set("port", 9090)
+		mustGetSliceFromMap(t, "tags", mut).Push("ours")
+		mut.Delete("retries")
+		ours := mut.Immutable()
+
+		mut = base.Mutable()
+		mustGetMapFromMap(t, "server", mut).Set("host", "example.com")
+		tags := mustGetSliceFromMap(t, "tags", mut)
+		tags.Set(0, "A")
+		tags.Push("theirs")
+		mut.Set("debug", true)
+		theirs := mut.Immutable()
+
+		merged, conflicts := Merge3(base, ours, theirs)
+		assert.Empty(t, conflicts)
+		assert.Equal(t, map[string]any{
+			"name":   "api",
+			"server": map[string]any{"port": 9090, "host": "example.com"},
+			"tags":   []any{"A", "b", "ours", "theirs"},
+			"limits": map[string]any{"rps": 100},
+			"debug":  true,
+		}, merged.Export())
+		assert.Equal(t, newBase(), base.Export())
+
+		// unchanged subtrees are shared
+		limits2, ok := merged.Get("limits")
+		require.True(t, ok)
+		assert.Same(t, limits, limits2)
+
+		// one-sided and identical changes
+		merged, conflicts = Merge3(base, ours, base)
+		assert.Empty(t, conflicts)
+		assert.Same(t, ours, merged)
+		merged, conflicts = Merge3(base, base, theirs)
+		assert.Empty(t, conflicts)
+		assert.Equal(t, theirs.Export(), merged.Export())
+		merged, conflicts = Merge3(base, ours, ours.Mutable().Immutable())
+		assert.Empty(t, conflicts)
+		assert.Same(t, ours, merged)
+
+		// unrelated maps are merged key by key
+		merged, conflicts = Merge3(
+			nil,
+			NewImmutableMap(map[string]any{"a": 1, "same": []any{1}}),
+			NewImmutableMap(map[string]any{"b": 2, "same": []any{1}}),
+		)
+		assert.Empty(t, conflicts)
+		assert.Equal(t, map[string]any{"a": 1, "b": 2, "same": []any{1}}, merged.Export())
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		base := NewImmutableMap(newBase())
+
+		mut := base.Mutable()
+		mustGetMapFromMap(t, "server", mut).Set("port", 9090)
+		mustGetSliceFromMap(t, "tags", mut).Pop()
+		mut.Set("name", "ours")
+		mut.Delete("limits")
+		ours := mut.Immutable()
+
+		mut = base.Mutable()
+		mustGetMapFromMap(t, "server", mut).Set("port", 9091)
+		mustGetSliceFromMap(t, "tags", mut).Set(1, "B")
+		mut.Set("name", "ours")
+		mustGetMapFromMap(t, "limits", mut).Set("rps", 200)
+		theirs := mut.Immutable()
+
+		merged, conflicts := Merge3(base, ours, theirs)
+		assert.Equal(t, ours.Export(), merged.Export())
+		require.Len(t, conflicts, 3)
+		assert.Equal(t, "/limits", conflicts[0].Path)
+		assert.True(t, Equal(conflicts[0].Base, map[string]any{"rps": 100}))
+		assert.Equal(t, Tombstone, conflicts[0].Ours)
+		assert.True(t, Equal(conflicts[0].Theirs, map[string]any{"rps": 200}))
+		assert.Equal(t, Conflict{Path: "/server/port", Base: 8080, Ours: 9090, Theirs: 9091}, conflicts[1])
+		assert.Equal(t, "/tags", conflicts[2].Path)
+
+		var seen []string
+		merged, conflicts = Merge3(base, ours, theirs, WithConflictResolver(func(c Conflict) (any, bool) {
+			seen = append(seen, c.Path)
+			switch c.Path {
+			case "/limits":
+				return Tombstone, true
+			case "/server/port":
+				return max(c.Ours.(int), c.Theirs.(int)), true
+			default:
+				return nil, false
+			}
+		}))
+		assert.Equal(t, []string{"/limits", "/server/port", "/tags"}, seen)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, "/tags", conflicts[0].Path)
+		assert.Equal(t, map[string]any{
+			"name":    "ours",
+			"server":  map[string]any{"port": 9091, "host": "localhost"},
+			"tags":    []any{"a"},
+			"retries": 3,
+		}, merged.Export())
+
+		// the resolver may remove slice elements
+		numbers := NewImmutableMap(map[string]any{"s": []any{1, 2, 3}})
+		mut = numbers.Mutable()
+		s := mustGetSliceFromMap(t, "s", mut)
+		s.Set(1, 20)
+		s.Push(4)
+		ours = mut.Immutable()
+		mut = numbers.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Set(1, 21)
+		theirs = mut.Immutable()
+		merged, conflicts = Merge3(numbers, ours, theirs, WithConflictResolver(func(Conflict) (any, bool) {
+			return Tombstone, true
+		}))
+		assert.Empty(t, conflicts)
+		assert.Equal(t, map[string]any{"s": []any{1, 3, 4}}, merged.Export())
+	})
+
+	t.Run("slices whose elements moved", func(t *testing.T) {
+		base := NewImmutableMap(map[string]any{"s": []any{0, 0}})
+		mut := base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).PushFront("z")
+		ours := mut.Immutable()
+		mut = base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Set(1, "B")
+		theirs := mut.Immutable()
+
+		// merging index by index would apply theirs to the wrong element
+		merged, conflicts := Merge3(base, ours, theirs)
+		assert.Equal(t, ours.Export(), merged.Export())
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, "/s", conflicts[0].Path)
+		assert.True(t, Equal(conflicts[0].Ours, []any{"z", 0, 0}))
+		assert.True(t, Equal(conflicts[0].Theirs, []any{0, "B"}))
+
+		// likewise for removed elements
+		mut = base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Insert(1, "y")
+		merged, conflicts = Merge3(base, mut.Immutable(), theirs)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, []any{0, "y", 0}, merged.Export()["s"])
+
+		// slices which are only set and appended to, over several
+		// canonizations, are still merged index by index
+		mut = base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Push(1)
+		mut = mut.Immutable().Mutable()
+		mustGetSliceFromMap(t, "s", mut).Set(0, "A")
+		merged, conflicts = Merge3(base, mut.Immutable(), theirs)
+		assert.Empty(t, conflicts)
+		assert.Equal(t, []any{"A", "B", 1}, merged.Export()["s"])
+
+		// as are vector-backed slices
+		base = NewImmutableMap(map[string]any{"s": NewImmutableSlice([]any{0, 0}, WithVector())})
+		mut = base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Set(0, "A")
+		ours = mut.Immutable()
+		mut = base.Mutable()
+		mustGetSliceFromMap(t, "s", mut).Set(1, "B")
+		merged, conflicts = Merge3(base, ours, mut.Immutable())
+		assert.Empty(t, conflicts)
+		assert.Equal(t, []any{"A", "B"}, merged.Export()["s"])
+	})
+
+	t.Run("HAMT-backed maps", func(t *testing.T) {
+		base := NewImmutableMap(newBase(), WithHAMT())
+		mut := base.Mutable()
+		mut.Set("name", "ours")
+		ours := mut.Immutable()
+		mut = base.Mutable()
+		mut.Set("retries", 5)
+		theirs := mut.Immutable()
+
+		merged, conflicts := Merge3(base, ours, theirs)
+		assert.Empty(t, conflicts)
+		require.NotNil(t, merged.trie)
+		assert.Equal(t, "ours", merged.Export()["name"])
+		assert.Equal(t, 5, merged.Export()["retries"])
+	})
+}
@@ -0,0 +1,122 @@
+package green
+
+import "fmt"
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to the Map. The patch may be
+// a map[string]any, *ImmutableMap, or *Map. Keys in the patch with a nil value
+// are deleted from the Map, keys with a map value are merged recursively, and
+// keys with any other value replace the value in the Map. If the patch is not
+// a map, an error wrapping ErrWrongType is returned and the Map is not
+// modified. If the Map is nil, this panics.
+//
+// The patch is applied with Set and Delete, so subtrees the patch does not
+// touch keep sharing the ImmutableMap the Map was derived from, and values
+// taken from the patch are shared rather than copied.
+//
+// This has O(p) average time complexity, where p is the number of nodes in the
+// patch.
+func (m *Map) MergePatch(patch any) error {
+	if m == nil {
+		panic("*green.Map.MergePatch: merge into nil map")
+	}
+
+	p, ok := asImmutable(patch).(*ImmutableMap)
+	if !ok {
+		return fmt.Errorf("green: %w: merge patch must be a map, got %s", ErrWrongType, describeType(patch))
+	}
+	mergePatch(m, p)
+	return nil
+}
+
+// CreateMergePatch returns an RFC 7396 JSON Merge Patch which turns a into b
+// when applied with MergePatch. Values in the patch are shared with b rather
+// than copied. Like Diff, this uses pointer equality to skip shared subtrees.
+//
+// Merge patches cannot express setting a value to null or changing individual
+// slice elements; slices which differ are replaced whole, and values which are
+// nil in b are indistinguishable from deletions.
+//
+// This has O(n) time complexity in the worst case, where n is the number of
+// nodes in the graphs of a and b.
+func CreateMergePatch(a, b *ImmutableMap) *ImmutableMap {
+	return NewImmutableMap(createMergePatch(a, b))
+}
+
+func mergePatch(target *Map, patch *ImmutableMap) {
+	for k, pv := range patch.All() {
+		if pv == nil {
+			target.Delete(k)
+			continue
+		}
+
+		pm, ok := pv.(*ImmutableMap)
+		if !ok {
+			target.Set(k, pv)
+			continue
+		}
+
+		tv, _ := target.Get(k)
+		tm, ok := tv.(*Map)
+		if !ok {
+			if !containsNil(pm) {
+				// nothing to strip, so the patch subtree can be shared as is
+				target.Set(k, pm)
+				continue
+			}
+			target.Set(k, map[string]any{})
+			tv, _ = target.Get(k)
+			tm = tv.(*Map)
+		}
+		mergePatch(tm, pm)
+	}
+}
+
+func createMergePatch(a, b *ImmutableMap) map[string]any {
+	patch := make(map[string]any)
+	if a == b {
+		return patch
+	}
+
+	for _, k := range diffKeys(a, b) {
+		aValue, aOK := a.Get(k)
+		bValue, bOK := b.Get(k)
+		if !bOK {
+			if aOK {
+				patch[k] = nil
+			}
+			continue
+		}
+		if !aOK {
+			patch[k] = bValue
+			continue
+		}
+
+		aMap, aIsMap := aValue.(*ImmutableMap)
+		bMap, bIsMap := bValue.(*ImmutableMap)
+		if aIsMap && bIsMap {
+			if sub := createMergePatch(aMap, bMap); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !Equal(aValue, bValue) {
+			patch[k] = bValue
+		}
+	}
+	return patch
+}
+
+// containsNil reports whether any value in the map or its nested maps is nil.
+func containsNil(m *ImmutableMap) bool {
+	for _, v := range m.All() {
+		switch v := v.(type) {
+		case nil:
+			return true
+		case *ImmutableMap:
+			if containsNil(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
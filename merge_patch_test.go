@@ -0,0 +1,103 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	t.Run("RFC 7396 examples", func(t *testing.T) {
+		tests := []struct {
+			target string
+			patch  string
+			expect string
+		}{
+			{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+			{`{"a":"b"}`, `{"a":null}`, `{}`},
+			{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+			{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+			{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+			{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+			{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+			{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		}
+
+		for _, tt := range tests {
+			im := NewImmutableMap(mustUnmarshalMap(t, tt.target))
+			mut := im.Mutable()
+			require.NoError(t, mut.MergePatch(mustUnmarshalMap(t, tt.patch)))
+			assert.Equal(t, mustUnmarshalMap(t, tt.expect), mut.Export(), "%s + %s", tt.target, tt.patch)
+			assert.Equal(t, mustUnmarshalMap(t, tt.expect), mut.Immutable().Export(), "%s + %s", tt.target, tt.patch)
+			assert.Equal(t, mustUnmarshalMap(t, tt.target), im.Export())
+		}
+	})
+
+	t.Run("patch types", func(t *testing.T) {
+		patch := map[string]any{"a": map[string]any{"b": 2}}
+		for _, p := range []any{patch, NewImmutableMap(patch), NewImmutableMap(patch).Mutable()} {
+			mut := NewImmutableMap(map[string]any{"a": map[string]any{"b": 1, "c": 1}}).Mutable()
+			require.NoError(t, mut.MergePatch(p))
+			assert.Equal(t, map[string]any{"a": map[string]any{"b": 2, "c": 1}}, mut.Export())
+		}
+
+		mut := NewImmutableMap(map[string]any{"a": 1}).Mutable()
+		assert.ErrorIs(t, mut.MergePatch([]any{1}), ErrWrongType)
+		assert.ErrorIs(t, mut.MergePatch("a"), ErrWrongType)
+		assert.Equal(t, map[string]any{"a": 1}, mut.Export())
+	})
+
+	t.Run("untouched subtrees are shared", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{
+			"touched":   map[string]any{"x": 1},
+			"untouched": map[string]any{"y": 1},
+		})
+		shared := NewImmutableMap(map[string]any{"z": 1})
+		mut := im.Mutable()
+		require.NoError(t, mut.MergePatch(map[string]any{
+			"touched": map[string]any{"x": 2},
+			"new":     shared,
+		}))
+		im2 := mut.Immutable()
+
+		untouched1, _ := im.Get("untouched")
+		untouched2, _ := im2.Get("untouched")
+		assert.Same(t, untouched1, untouched2)
+		newValue, _ := im2.Get("new")
+		assert.Same(t, shared, newValue)
+	})
+
+	t.Run("CreateMergePatch", func(t *testing.T) {
+		a := NewImmutableMap(map[string]any{
+			"same":   map[string]any{"x": 1},
+			"nested": map[string]any{"keep": 1, "change": 1, "remove": 1},
+			"list":   []any{1, 2},
+			"gone":   "bye",
+		})
+		mut := a.Mutable()
+		require.NoError(t, mut.SetPath("/nested/change", 2))
+		require.NoError(t, mut.DeletePath("/nested/remove"))
+		require.NoError(t, mut.SetPath("/list/-", 3))
+		mut.Delete("gone")
+		mut.Set("added", map[string]any{"k": "v"})
+		b := mut.Immutable()
+
+		patch := CreateMergePatch(a, b)
+		assert.Equal(t, map[string]any{
+			"nested": map[string]any{"change": 2, "remove": nil},
+			"list":   []any{1, 2, 3},
+			"gone":   nil,
+			"added":  map[string]any{"k": "v"},
+		}, patch.Export())
+
+		target := a.Mutable()
+		require.NoError(t, target.MergePatch(patch))
+		assert.True(t, Equal(b, target))
+
+		assert.Equal(t, 0, CreateMergePatch(a, a).Len())
+		assert.Equal(t, 0, CreateMergePatch(a, NewImmutableMap(a.Export())).Len())
+	})
+}
@@ -0,0 +1,103 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	newDefaults := func() map[string]any {
+		return map[string]any{
+			"name": "api",
+			"server": map[string]any{
+				"port":    8080,
+				"timeout": map[string]any{"read": 5, "write": 5},
+			},
+			"tags":     []any{"a", "b"},
+			"backends": []any{map[string]any{"id": "x", "weight": 1}, map[string]any{"id": "y", "weight": 1}},
+			"limits":   map[string]any{"rps": 100},
+		}
+	}
+
+	t.Run("maps merge recursively", func(t *testing.T) {
+		defaults := NewImmutableMap(newDefaults())
+		limits, ok := defaults.Get("limits")
+		require.True(t, ok)
+
+		env := map[string]any{"server": map[string]any{"port": 9090, "timeout": map[string]any{"read": 10}}}
+		tenant := NewImmutableMap(map[string]any{"name": "tenant-api", "tags": []any{"c"}, "debug": nil}).Mutable()
+		merged := Merge(defaults, env, nil, tenant)
+
+		want := newDefaults()
+		want["name"] = "tenant-api"
+		want["server"] = map[string]any{"port": 9090, "timeout": map[string]any{"read": 10, "write": 5}}
+		want["tags"] = []any{"c"}
+		want["debug"] = nil
+		assert.Equal(t, want, merged.Export())
+		assert.Equal(t, newDefaults(), defaults.Export())
+		assert.Equal(t, map[string]any{"server": map[string]any{"port": 9090, "timeout": map[string]any{"read": 10}}}, env)
+
+		// untouched subtrees are shared with base
+		limits2, ok := merged.Get("limits")
+		require.True(t, ok)
+		assert.Same(t, limits, limits2)
+
+		assert.Same(t, defaults, Merge(defaults))
+		assert.Equal(t, map[string]any{"a": 1}, Merge(nil, map[string]any{"a": 1}).Export())
+		assert.PanicsWithValue(t, "green.Merge: overlay 1 must be a map, got slice", func() {
+			Merge(defaults, env, []any{1})
+		})
+	})
+
+	t.Run("slice strategies", func(t *testing.T) {
+		defaults := NewImmutableMap(newDefaults())
+		overlay := map[string]any{
+			"tags":     []any{"c"},
+			"backends": []any{map[string]any{"id": "y", "weight": 5}, map[string]any{"id": "z"}, "w"},
+		}
+
+		merged := Merge(defaults, overlay, WithSliceStrategy(SliceAppend))
+		assert.Equal(t, []any{"a", "b", "c"}, merged.Export()["tags"])
+		assert.Equal(t, []any{
+			map[string]any{"id": "x", "weight": 1},
+			map[string]any{"id": "y", "weight": 1},
+			map[string]any{"id": "y", "weight": 5},
+			map[string]any{"id": "z"},
+			"w",
+		}, merged.Export()["backends"])
+
+		merged = Merge(defaults, WithSliceStrategy(SliceMergeByIndex), overlay)
+		assert.Equal(t, []any{"c", "b"}, merged.Export()["tags"])
+		assert.Equal(t, []any{
+			map[string]any{"id": "y", "weight": 5},
+			map[string]any{"id": "z", "weight": 1},
+			"w",
+		}, merged.Export()["backends"])
+
+		merged = Merge(defaults, overlay, map[string]any{"backends": []any{map[string]any{"id": "y", "zone": "eu"}}}, WithMergeKey("id"))
+		assert.Equal(t, []any{"a", "b", "c"}, merged.Export()["tags"])
+		assert.Equal(t, []any{
+			map[string]any{"id": "x", "weight": 1},
+			map[string]any{"id": "y", "weight": 5, "zone": "eu"},
+			map[string]any{"id": "z"},
+			"w",
+		}, merged.Export()["backends"])
+
+		// non-string keys are matched by value
+		numbered := NewImmutableMap(map[string]any{"items": []any{map[string]any{"n": 1, "v": "a"}}})
+		merged = Merge(numbered, map[string]any{"items": []any{map[string]any{"n": 1.0, "v": "b"}, map[string]any{"n": 1, "w": "c"}}}, WithMergeKey("n"))
+		assert.Equal(t, []any{map[string]any{"n": 1, "v": "b", "w": "c"}}, merged.Export()["items"])
+
+		merged = Merge(defaults, overlay)
+		assert.Equal(t, []any{"c"}, merged.Export()["tags"])
+	})
+
+	t.Run("HAMT-backed base", func(t *testing.T) {
+		defaults := NewImmutableMap(newDefaults(), WithHAMT())
+		merged := Merge(defaults, map[string]any{"name": "other"})
+		require.NotNil(t, merged.trie)
+		assert.Equal(t, "other", merged.Export()["name"])
+	})
+}
@@ -1,12 +1,12 @@
 package green
 
 import (
-	"encoding/json"
 	"fmt"
 	"iter"
 	"maps"
 	"slices"
-	"sync"
+	"strconv"
+	"weak"
 )
 
 type (
@@ -32,6 +32,11 @@ type (
 		// len is tracked manually as the Map is mutated to provide O(1) Len()
 		// calls.
 		len int
+		// log, if not nil, records mutations. See StartRecording.
+		log *MutationLog
+		// recording is the number of containers at or above this one which
+		// are recording mutations.
+		recording int
 	}
 
 	// Slice provides a mutable slice of values.
@@ -57,6 +62,11 @@ type (
 		// dirty tracks whether this slice or a nested container has been
 		// mutated since creation.
 		dirty bool
+		// log, if not nil, records mutations. See StartRecording.
+		log *MutationLog
+		// recording is the number of containers at or above this one which
+		// are recording mutations.
+		recording int
 	}
 )
 
@@ -146,6 +156,9 @@ func (m *Map) Set(key string, val any) {
 		m.len++
 	}
 	m.reportDirty()
+	if m.recording > 0 {
+		m.record(Mutation{Op: MutationSet, Value: asImmutable(val)}, []string{key})
+	}
 }
 
 // Delete removes the value for the given key in the Map. If the Map is nil,
@@ -162,6 +175,9 @@ func (m *Map) Delete(key string) {
 	if keyExisted {
 		m.len--
 		m.reportDirty()
+		if m.recording > 0 {
+			m.record(Mutation{Op: MutationDelete}, []string{key})
+		}
 	}
 }
 
@@ -240,8 +256,16 @@ func (m *Map) allRaw() iter.Seq2[string, ImmutableValue] {
 // the Map do not affect the returned ImmutableMap. If the Map is nil, this
 // returns nil.
 //
+// The returned ImmutableMap shares the ImmutableMap the Map was derived from,
+// and only holds the Map's changes on top of it, or shares its Compact instead
+// if the chain has reached the threshold set with WithCompactThreshold. If the
+// ImmutableMap the Map was derived from is backed by a HAMT, the returned
+// ImmutableMap is a new version of that HAMT instead. See WithHAMT.
+//
 // This has O(k) time complexity, where k is the total number of dirty nodes in
-// the graph representing the underlying value.
+// the graph representing the underlying value, except when first compacting
+// the ImmutableMap the Map was derived from, which has O(n*d) time complexity
+// as described by ImmutableMap.Compact.
 func (m *Map) Immutable() *ImmutableMap {
 	if m == nil {
 		return nil
@@ -258,15 +282,31 @@ func (m *Map) Immutable() *ImmutableMap {
 		case *Slice:
 			newOverwrites[k] = v.Immutable()
 		default:
-			newOverwrites[k] = v
+			// wrap native containers so that Get on the ImmutableMap never
+			// exposes them
+			newOverwrites[k], _ = isContainer(v)
+		}
+	}
+	if m.base != nil && m.base.trie != nil {
+		return &ImmutableMap{trie: m.base.trie.update(newOverwrites)}
+	}
+
+	base := m.base
+	var threshold int
+	if base != nil {
+		threshold = base.compactThreshold
+		if threshold > 0 && base.depth >= threshold {
+			base = base.Compact()
 		}
 	}
 	return &ImmutableMap{
 		inherited: &Map{
 			overwrites: newOverwrites,
-			base:       m.base,
+			base:       base,
 			len:        m.Len(),
 		},
+		depth:            base.ChainDepth() + 1,
+		compactThreshold: threshold,
 	}
 }
 
@@ -305,6 +345,18 @@ func (m *Map) Clone() *Map {
 	return m2
 }
 
+// DeepClone returns a deep copy of the Map. Unlike Clone, no containers are
+// shared between the original and the clone, so mutations to either, at any
+// depth, do not affect the other. Untouched values are not copied; instead,
+// the clone is derived from an ImmutableMap holding the Map's current state,
+// which shares everything the Map has not modified. If the Map is nil, this
+// returns nil.
+//
+// This has the same time complexity as Immutable.
+func (m *Map) DeepClone() *Map {
+	return m.Immutable().Mutable()
+}
+
 // Export returns a deep copy of the Map, with all values converted to the
 // native Go types of the underlying values. Modifying this map does not affect
 // the Map, nor any values used as inputs, nor any values returned from future
@@ -332,11 +384,7 @@ func (m *Map) MarshalJSON() ([]byte, error) {
 	if !m.dirty {
 		return m.base.MarshalJSON()
 	}
-	tmpMap := make(map[string]any, m.Len())
-	for k, v := range m.All() {
-		tmpMap[k] = v
-	}
-	return json.Marshal(tmpMap)
+	return marshalJSON(m)
 }
 
 // At retrieves the Value at the specified index. Like a native Go slice, if the
@@ -411,6 +459,9 @@ func (s *Slice) Set(index int, val any) {
 	}
 
 	s.reportDirty()
+	if s.recording > 0 {
+		s.record(Mutation{Op: MutationSet, Value: asImmutable(val)}, []string{strconv.Itoa(index)})
+	}
 
 	// in prepends?
 	if index < len(s.prepends) {
@@ -451,6 +502,9 @@ func (s *Slice) Push(val any) {
 
 	s.appends = append(s.appends, val)
 	s.reportDirty()
+	if s.recording > 0 {
+		s.record(Mutation{Op: MutationPush, Value: asImmutable(val)}, nil)
+	}
 }
 
 // PushFront prepends the given value to the front of the Slice. Note that this
@@ -466,6 +520,9 @@ func (s *Slice) PushFront(val any) {
 
 	s.prepends = append(s.prepends, val)
 	s.reportDirty()
+	if s.recording > 0 {
+		s.record(Mutation{Op: MutationPushFront, Value: asImmutable(val)}, nil)
+	}
 }
 
 // ReSlice adjusts the bounds of the Slice to the given left index (inclusive)
@@ -475,14 +532,15 @@ func (s *Slice) PushFront(val any) {
 // existing references to containers within the before the ReSlice call are
 // still shared.
 //
-// This has O(left-right) average time complexity.
+// This has O(1) average time complexity.
 func (s *Slice) ReSlice(left, right int) {
-	s2 := s.subSlice(left, right, "ReSlice")
-	if s2 == s {
+	if !s.reslice(left, right, "ReSlice") {
 		return
 	}
-	*s = *s2
 	s.reportDirty()
+	if s.recording > 0 {
+		s.record(Mutation{Op: MutationReSlice, Left: left, Right: right}, nil)
+	}
 }
 
 // SubSlice returns a new Slice representing the shallow subslice of the
@@ -493,7 +551,7 @@ func (s *Slice) ReSlice(left, right int) {
 //
 // This has O(left-right) average time complexity.
 func (s *Slice) SubSlice(left, right int) *Slice {
-	return s.subSlice(left, right, "SubSlice")
+	return s.subSlice(left, right, "SubSlice", true)
 }
 
 // All returns an iterator over all index, value pairs in the Slice in order.
@@ -541,10 +599,46 @@ func (s *Slice) All() iter.Seq2[int, Value] {
 	}
 }
 
+// allRaw is like All, but yields values without wrapping them in mutable
+// containers.
+func (s *Slice) allRaw() iter.Seq2[int, any] {
+	return func(yield func(int, any) bool) {
+		if s == nil {
+			return
+		}
+		i := 0
+		for j := s.prependIndex(0); j >= 0; j-- {
+			if !yield(i, s.prepends[j]) {
+				return
+			}
+			i++
+		}
+		for j, v := range s.base.All() {
+			if v2, ok := s.getOverride(j); ok {
+				v = v2
+			}
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+		for _, v := range s.appends {
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
 // Immutable returns an immutable version of the Slice. Subsequent mutations to
 // the Slice do not affect the returned ImmutableSlice. If the Slice is nil,
 // this returns nil.
 //
+// If the ImmutableSlice the Slice was derived from is backed by a vector, the
+// returned ImmutableSlice is a new version of that vector, which only copies
+// the paths to the elements written. See WithVector.
+//
 // This has O(k) time complexity, where k is the total number of dirty nodes in
 // the graph representing the underlying value.
 func (s *Slice) Immutable() *ImmutableSlice {
@@ -555,36 +649,74 @@ func (s *Slice) Immutable() *ImmutableSlice {
 		return s.base
 	}
 
+	var is *ImmutableSlice
+	if s.base.vec != nil {
+		is = &ImmutableSlice{vec: s.immutableVector()}
+	} else {
+		is = &ImmutableSlice{base: s.immutableBase()}
+	}
+	if len(s.prepends) == 0 && s.overwriteOffset == 0 {
+		// the elements of the base kept their indexes, since anything which
+		// moves them reslices the base
+		is.origin = weak.Make(s.base)
+	}
+	return is
+}
+
+// immutableBase returns the elements of the Slice, canonized.
+func (s *Slice) immutableBase() []any {
+
 	is := make([]any, s.Len())
-	j := 0
-	addElement := func(v any) {
+	// we don't call s.All() because that eagerly wraps as Values
+	for i, v := range s.allRaw() {
 		switch v := v.(type) {
 		case *Map:
-			is[j] = v.Immutable()
+			is[i] = v.Immutable()
 		case *Slice:
-			is[j] = v.Immutable()
+			is[i] = v.Immutable()
 		default:
-			is[j] = v
+			is[i] = v
 		}
-		j++
 	}
+	return is
+}
 
-	// we don't call m.All() because that eagerly wraps as Values
-	for i := s.prependIndex(0); i >= 0; i-- {
-		v := s.prepends[i]
-		addElement(v)
+// immutableVector applies the Slice's changes to the vector backing its base.
+func (s *Slice) immutableVector() *vector {
+	vec := s.base.vec
+	for i, v := range s.overwrites {
+		i -= s.overwriteOffset
+		if i < 0 || i >= vec.len() {
+			// sliced away
+			continue
+		}
+		iv := asImmutable(v)
+		if sameContainer(iv, vec.get(i)) {
+			// wrapped on access, but not modified
+			continue
+		}
+		vec = vec.set(i, iv)
 	}
-	for i, v := range s.base.All() {
-		if v2, ok := s.getOverride(i); ok {
-			v = v2
+
+	// pushing fills the edge leaves, while concatenating attaches new ones,
+	// which pays off for larger batches
+	if len(s.prepends) > vectorWidth {
+		prepends := slices.Clone(s.prepends)
+		slices.Reverse(prepends)
+		vec = newVector(prepends, asImmutable).concat(vec)
+	} else {
+		for _, v := range s.prepends {
+			vec = vec.pushFront(asImmutable(v))
 		}
-		addElement(v)
 	}
-	for _, v := range s.appends {
-		addElement(v)
+	if len(s.appends) > vectorWidth {
+		vec = vec.concat(newVector(s.appends, asImmutable))
+	} else {
+		for _, v := range s.appends {
+			vec = vec.push(asImmutable(v))
+		}
 	}
-
-	return &ImmutableSlice{base: is}
+	return vec
 }
 
 // Clone returns a shallow copy of the Slice. Subsequent mutations to the clone
@@ -623,6 +755,14 @@ func (s *Slice) Clone() *Slice {
 	return s2
 }
 
+// DeepClone is like Map.DeepClone, but for a Slice. If the Slice is nil, this
+// returns nil.
+//
+// This has the same time complexity as Immutable.
+func (s *Slice) DeepClone() *Slice {
+	return s.Immutable().Mutable()
+}
+
 // Export returns a deep copy of the slice, with all values converted to the
 // native Go types of the underlying values. Modifying this slice does not
 // affect the Slice, nor any values used as inputs, nor any values returned from
@@ -650,18 +790,20 @@ func (s *Slice) MarshalJSON() ([]byte, error) {
 	if !s.dirty {
 		return s.base.MarshalJSON()
 	}
-	tmpSlice := make([]any, s.Len())
-	for i, v := range s.All() {
-		tmpSlice[i] = v
-	}
-	return json.Marshal(tmpSlice)
+	return marshalJSON(s)
 }
 
 type (
 	// reportable is an interface which mutable containers implement. It is used
-	// to signal dirty state to parent containers.
+	// to signal dirty state and recorded mutations to parent containers.
 	reportable interface {
 		reportDirty()
+		// recordFrom records a mutation of the given child container, at the
+		// given path relative to the child.
+		recordFrom(child any, mut Mutation, tokens []string)
+		// recorders returns the number of containers at or above this one
+		// which are recording mutations.
+		recorders() int
 	}
 )
 
@@ -670,18 +812,22 @@ func asNewMutableContainer(v any, parent reportable) (any, bool) {
 	case *ImmutableMap:
 		v2 := v.Mutable()
 		v2.parents = []reportable{parent}
+		v2.recording = parent.recorders()
 		return v2, true
 	case *ImmutableSlice:
 		v2 := v.Mutable()
 		v2.parents = []reportable{parent}
+		v2.recording = parent.recorders()
 		return v2, true
 	case map[string]any:
 		v2 := NewImmutableMap(v).Mutable()
 		v2.parents = []reportable{parent}
+		v2.recording = parent.recorders()
 		return v2, true
 	case []any:
 		v2 := NewImmutableSlice(v).Mutable()
 		v2.parents = []reportable{parent}
+		v2.recording = parent.recorders()
 		return v2, true
 	default:
 		return v, false
@@ -697,17 +843,14 @@ func (m *Map) handleNewValue(k string, v any) any {
 }
 
 func (m *Map) reportDirty() {
-	if !m.dirty {
-		m.dirty = true
-		var wg sync.WaitGroup
-		for _, p := range m.parents {
-			wg.Add(1)
-			go func(p reportable) {
-				defer wg.Done()
-				p.reportDirty()
-			}(p)
-		}
-		wg.Wait()
+	// ancestors which are already dirty have reported to their own parents,
+	// so the walk stops at the first of them
+	if m.dirty {
+		return
+	}
+	m.dirty = true
+	for _, p := range m.parents {
+		p.reportDirty()
 	}
 }
 
@@ -719,17 +862,14 @@ func (m *Map) setOverwrite(k string, v any) {
 }
 
 func (s *Slice) reportDirty() {
-	if !s.dirty {
-		s.dirty = true
-		var wg sync.WaitGroup
-		for _, p := range s.parents {
-			wg.Add(1)
-			go func(p reportable) {
-				defer wg.Done()
-				p.reportDirty()
-			}(p)
-		}
-		wg.Wait()
+	// ancestors which are already dirty have reported to their own parents,
+	// so the walk stops at the first of them
+	if s.dirty {
+		return
+	}
+	s.dirty = true
+	for _, p := range s.parents {
+		p.reportDirty()
 	}
 }
 
@@ -744,7 +884,25 @@ func (s *Slice) prependIndex(i int) int {
 	return len(s.prepends) - 1 - i
 }
 
-func (s *Slice) subSlice(l, r int, funcName string) *Slice {
+// reslice is like ReSlice, but neither reports nor records the change. It
+// returns whether the bounds changed.
+func (s *Slice) reslice(left, right int, funcName string) bool {
+	// nested values are wrapped on access as usual, since only s holds the
+	// new bounds
+	s2 := s.subSlice(left, right, funcName, false)
+	if s2 == s {
+		return false
+	}
+	log, recording := s.log, s.recording
+	*s = *s2
+	s.log, s.recording = log, recording
+	return true
+}
+
+// subSlice returns a Slice sharing the overlays of s. If wrap is true, the
+// immediately nested values in bounds are wrapped first, so that s and the
+// returned Slice share them.
+func (s *Slice) subSlice(l, r int, funcName string, wrap bool) *Slice {
 	if l < 0 {
 		panic(fmt.Sprintf("*green.Slice.%s: index out of range [%d]", funcName, l))
 	}
@@ -755,15 +913,15 @@ func (s *Slice) subSlice(l, r int, funcName string) *Slice {
 		panic(fmt.Sprintf("*green.Slice.%s: slice bounds out of range [%d:%d]", funcName, l, r))
 	}
 
-	if l == 0 && r == s.Len()-1 {
+	if l == 0 && r == s.Len() {
 		return s
 	}
 
 	var (
-		newPrepends        []any
+		newPrepends        = s.prepends
 		newBase            *ImmutableSlice
-		newOverwriteOffset int
-		newAppends         []any
+		newOverwriteOffset = s.overwriteOffset
+		newAppends         = s.appends
 	)
 
 	// trim from the front
@@ -806,29 +964,42 @@ func (s *Slice) subSlice(l, r int, funcName string) *Slice {
 		newBase = s.base
 	}
 
-	// force wrapping of immediately nested values
-	for i, v := range newPrepends {
-		v, ok := asNewMutableContainer(v, s)
-		if ok {
-			newPrepends[i] = v // updates underlying array too
-		}
-	}
-	for i, v := range newAppends {
-		v, ok := asNewMutableContainer(v, s)
-		if ok {
-			newAppends[i] = v // updates underlying array too
+	if wrap {
+		// force wrapping of immediately nested values
+		for i, v := range newPrepends {
+			v, ok := asNewMutableContainer(v, s)
+			if ok {
+				newPrepends[i] = v // updates underlying array too
+			}
 		}
-	}
-	for i, v := range newBase.All() {
-		if v2, ok := s.getOverride(i + newOverwriteOffset); ok {
-			v = v2
+		for i, v := range newAppends {
+			v, ok := asNewMutableContainer(v, s)
+			if ok {
+				newAppends[i] = v // updates underlying array too
+			}
 		}
-		v, ok := asNewMutableContainer(v, s)
-		if ok {
-			s.setOverwrite(i+newOverwriteOffset, v)
+		for i, v := range newBase.All() {
+			// overwrite keys are relative to the original base, so don't apply
+			// s.overwriteOffset a second time
+			key := i + newOverwriteOffset
+			if v2, ok := s.overwrites[key]; ok {
+				v = v2
+			}
+			v, ok := asNewMutableContainer(v, s)
+			if ok {
+				if s.overwrites == nil {
+					s.overwrites = make(map[int]any)
+				}
+				s.overwrites[key] = v
+			}
 		}
 	}
 
+	// clip so that pushing onto the subslice can't overwrite elements of the
+	// original past its end
+	newPrepends = slices.Clip(newPrepends)
+	newAppends = slices.Clip(newAppends)
+
 	return &Slice{
 		base:            newBase,
 		overwrites:      s.overwrites,
@@ -9,7 +9,7 @@ import (
 func BenchmarkMutable(b *testing.B) {
 
 	numCopies := []int{1, 11, 21, 31, 41, 51}
-	numEventSizes := []int{1000}
+	numEventSizes := []int{101}
 	numCopiesTest := false
 	if !numCopiesTest {
 		numCopies = []int{10}
@@ -294,6 +294,21 @@ func TestMutable(t *testing.T) {
 			assert.Equal(t, expectIm2, im2.Export())
 		})
 
+		t.Run("reporting dirty to ancestors doesn't allocate", func(t *testing.T) {
+			mut := NewImmutableMap(map[string]any{
+				"k1": map[string]any{"k2": []any{"ne1"}},
+			}).Mutable()
+			k1 := mustGetMapFromMap(t, "k1", mut)
+			k2 := mustGetSliceFromMap(t, "k2", k1)
+
+			allocs := testing.AllocsPerRun(100, func() {
+				mut.dirty, k1.dirty, k2.dirty = false, false, false
+				k2.reportDirty()
+			})
+			assert.Zero(t, allocs)
+			assert.True(t, mut.dirty)
+		})
+
 		t.Run("map is dirty from nested slice mutation", func(t *testing.T) {
 			m := map[string]any{
 				"k2": []any{"ne1"},
@@ -432,6 +447,20 @@ func TestMutable(t *testing.T) {
 			assert.Same(t, sm1, sm2)
 		})
 
+		t.Run("native containers set on a map are immutable after canonizing", func(t *testing.T) {
+			mut := NewImmutableMap(map[string]any{}).Mutable()
+			mut.Set("m", map[string]any{"k": "v"})
+			mut.Set("s", []any{"e"})
+			im := mut.Immutable()
+
+			m, ok := im.Get("m")
+			require.True(t, ok)
+			assert.IsType(t, &ImmutableMap{}, m)
+			s, ok := im.Get("s")
+			require.True(t, ok)
+			assert.IsType(t, &ImmutableSlice{}, s)
+		})
+
 		t.Run("clone a dirty map", func(t *testing.T) {
 			raw := map[string]any{}
 			im := NewImmutableMap(raw)
@@ -448,6 +477,49 @@ func TestMutable(t *testing.T) {
 			assert.Equal(t, "bar", foo2Clone)
 		})
 
+		t.Run("deep clone", func(t *testing.T) {
+			im := NewImmutableMap(map[string]any{
+				"owner":  map[string]any{"name": "Sam"},
+				"tricks": []any{"sit", map[string]any{"name": "roll"}},
+				"vet":    map[string]any{"name": "Dr. Who"},
+			})
+			vet, ok := im.Get("vet")
+			require.True(t, ok)
+
+			mut := im.Mutable()
+			mustGetMapFromMap(t, "owner", mut).Set("name", "Alex")
+			mut.Set("age", 6)
+			clone := mut.DeepClone()
+			assert.True(t, Equal(mut, clone))
+
+			// mutations at any depth stay on their side
+			mustGetMapFromMap(t, "owner", mut).Set("city", "Bern")
+			mustGetMapFromSlice(t, 1, mustGetSliceFromMap(t, "tricks", clone)).Set("name", "play dead")
+			mustGetSliceFromMap(t, "tricks", mut).Push("shake")
+			mustGetMapFromMap(t, "vet", clone).Set("name", "Dr. No")
+
+			assert.Equal(t, map[string]any{
+				"owner":  map[string]any{"name": "Alex", "city": "Bern"},
+				"tricks": []any{"sit", map[string]any{"name": "roll"}, "shake"},
+				"vet":    map[string]any{"name": "Dr. Who"},
+				"age":    6,
+			}, mut.Export())
+			assert.Equal(t, map[string]any{
+				"owner":  map[string]any{"name": "Alex"},
+				"tricks": []any{"sit", map[string]any{"name": "play dead"}},
+				"vet":    map[string]any{"name": "Dr. No"},
+				"age":    6,
+			}, clone.Export())
+
+			// untouched immutable values are shared
+			vet2, ok := mut.Immutable().Get("vet")
+			require.True(t, ok)
+			assert.Same(t, vet, vet2)
+
+			var nilMap *Map
+			assert.Nil(t, nilMap.DeepClone())
+		})
+
 		t.Run("nested immutable map instance management", func(t *testing.T) {
 			m := map[string]any{
 				"k1": map[string]any{},
@@ -1055,6 +1127,58 @@ func TestMutable(t *testing.T) {
 			assert.Equal(t, "e1", mut.At(0))
 		})
 
+		t.Run("ReSlice/SubSlice keeping prepends or appends", func(t *testing.T) {
+			is := NewImmutableSlice([]any{"e2", "e3"})
+			mut := is.Mutable()
+			mut.PushFront("e1")
+			mut.Push("e4")
+
+			assert.Equal(t, []any{"e1", "e2", "e3"}, mut.SubSlice(0, 3).Export())
+			assert.Equal(t, []any{"e2", "e3", "e4"}, mut.SubSlice(1, 4).Export())
+			assert.Equal(t, []any{"e1", "e2", "e3", "e4"}, mut.SubSlice(0, 4).Export())
+
+			mut.ReSlice(1, 4)
+			assert.Equal(t, []any{"e2", "e3", "e4"}, mut.Export())
+			mut.ReSlice(1, 3)
+			assert.Equal(t, []any{"e3", "e4"}, mut.Export())
+			mut.ReSlice(0, 1)
+			assert.Equal(t, []any{"e3"}, mut.Export())
+			assert.Equal(t, []any{"e3"}, mut.Immutable().Export())
+		})
+
+		t.Run("pushing onto a SubSlice", func(t *testing.T) {
+			mut := NewImmutableSlice([]any{"e3"}).Mutable()
+			mut.Push("e4")
+			mut.Push("e5")
+			mut.Push("e6")
+			mut.PushFront("e2")
+			mut.PushFront("e1")
+			mut.PushFront("e0")
+
+			sub := mut.SubSlice(1, 6)
+			sub.Push("x")
+			sub.PushFront("y")
+			assert.Equal(t, []any{"y", "e1", "e2", "e3", "e4", "e5", "x"}, sub.Export())
+			assert.Equal(t, []any{"e0", "e1", "e2", "e3", "e4", "e5", "e6"}, mut.Export())
+		})
+
+		t.Run("repeated ReSlice with nested containers", func(t *testing.T) {
+			is := NewImmutableSlice([]any{
+				map[string]any{"k": 1},
+				map[string]any{"k": 2},
+				map[string]any{"k": 3},
+				map[string]any{"k": 4},
+			})
+			mut := is.Mutable()
+			mut.ReSlice(1, 4)
+			mut.ReSlice(1, 3)
+			require.Equal(t, 2, mut.Len())
+			m := mustGetMapFromSlice(t, 0, mut)
+			m.Set("k", 30)
+			assert.Equal(t, []any{map[string]any{"k": 30}, map[string]any{"k": 4}}, mut.Export())
+			assert.Equal(t, []any{map[string]any{"k": 30}, map[string]any{"k": 4}}, mut.Immutable().Export())
+		})
+
 		t.Run("clone with child map", func(t *testing.T) {
 			p := NewImmutableSlice([]any{map[string]any{"sub": "val"}})
 			mp1 := p.Mutable()
@@ -1162,6 +1286,24 @@ func TestMutable(t *testing.T) {
 			assert.Equal(t, "bar", foo2Clone)
 		})
 
+		t.Run("deep clone", func(t *testing.T) {
+			is := NewImmutableSlice([]any{map[string]any{"name": "Sam"}, []any{1, 2}})
+			mut := is.Mutable()
+			mut.PushFront("first")
+			clone := mut.DeepClone()
+
+			mustGetMapFromSlice(t, 1, mut).Set("name", "Alex")
+			mustGetSliceFromSlice(t, 2, clone).Push(3)
+			clone.Push("last")
+
+			assert.Equal(t, []any{"first", map[string]any{"name": "Alex"}, []any{1, 2}}, mut.Export())
+			assert.Equal(t, []any{"first", map[string]any{"name": "Sam"}, []any{1, 2, 3}, "last"}, clone.Export())
+			assert.Equal(t, []any{map[string]any{"name": "Sam"}, []any{1, 2}}, is.Export())
+
+			var nilSlice *Slice
+			assert.Nil(t, nilSlice.DeepClone())
+		})
+
 		t.Run("nested immutable slice instance management", func(t *testing.T) {
 			s := []any{
 				map[string]any{},
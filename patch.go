@@ -0,0 +1,409 @@
+package green
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The operations of a JSON Patch document (RFC 6902).
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchCopy    = "copy"
+	PatchTest    = "test"
+)
+
+// ErrTestFailed is returned when applying a "test" operation of a JSON Patch
+// whose value does not match the target value.
+var ErrTestFailed = errors.New("test failed")
+
+type (
+	// Patch is a JSON Patch document (RFC 6902): a sequence of operations
+	// which are applied in order.
+	Patch []PatchOperation
+
+	// PatchOperation is a single operation of a JSON Patch document.
+	PatchOperation struct {
+		// Op is one of PatchAdd, PatchRemove, PatchReplace, PatchMove,
+		// PatchCopy, or PatchTest.
+		Op string
+		// Path is the JSON Pointer (RFC 6901) of the target location.
+		Path string
+		// From is the JSON Pointer of the source location for PatchMove and
+		// PatchCopy operations.
+		From string
+		// Value is the value to add, replace, or test against for PatchAdd,
+		// PatchReplace, and PatchTest operations. It may be a native Go value
+		// or a green container.
+		Value any
+	}
+)
+
+// CreatePatch returns a JSON Patch which turns a into b when applied. The
+// patch is derived from Diff(a, b), so it shares Diff's pointer-equality
+// short-circuits, and its values are the immutable values found in b.
+//
+// This has the same time complexity as Diff.
+func CreatePatch(a, b ImmutableValue) Patch {
+	changes := Diff(a, b)
+	patch := make(Patch, len(changes))
+	for i, c := range changes {
+		switch c.Kind {
+		case ChangeAdded:
+			patch[i] = PatchOperation{Op: PatchAdd, Path: c.Path, Value: c.New}
+		case ChangeRemoved:
+			patch[i] = PatchOperation{Op: PatchRemove, Path: c.Path}
+		default:
+			patch[i] = PatchOperation{Op: PatchReplace, Path: c.Path, Value: c.New}
+		}
+	}
+	return patch
+}
+
+// ParsePatch decodes a JSON Patch document.
+func ParsePatch(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ApplyPatch applies the JSON Patch to the Map. Operations go through the same
+// copy-on-write paths as Set and Delete, so the ImmutableMap the Map was
+// derived from is never modified. Either all operations are applied or, if any
+// operation fails (including a "test" operation whose value does not match),
+// an error is returned and the Map is left unchanged. If the Map is nil, this
+// panics.
+//
+// This has O(p*d) average time complexity for patches that only touch Map
+// keys, where p is the number of operations and d is the depth of their
+// paths; operations on Slice elements additionally cost O(l), where l is the
+// length of the Slice.
+func (m *Map) ApplyPatch(patch Patch) error {
+	if m == nil {
+		panic("*green.Map.ApplyPatch: apply to nil map")
+	}
+	// dry run against a scratch copy so a failing operation can't leave the
+	// Map partially patched
+	if err := applyPatch(m.Immutable().Mutable(), patch); err != nil {
+		return err
+	}
+	return applyPatch(m, patch)
+}
+
+// ApplyPatch applies the JSON Patch to the Slice. See Map.ApplyPatch for
+// details. If the Slice is nil, this panics.
+//
+// This has the same time complexity as Map.ApplyPatch.
+func (s *Slice) ApplyPatch(patch Patch) error {
+	if s == nil {
+		panic("*green.Slice.ApplyPatch: apply to nil slice")
+	}
+	if err := applyPatch(s.Immutable().Mutable(), patch); err != nil {
+		return err
+	}
+	return applyPatch(s, patch)
+}
+
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Op    string  `json:"op"`
+		Path  string  `json:"path"`
+		From  *string `json:"from,omitempty"`
+		Value *any    `json:"value,omitempty"`
+	}{Op: op.Op, Path: op.Path}
+	switch op.Op {
+	case PatchMove, PatchCopy:
+		out.From = &op.From
+	case PatchAdd, PatchReplace, PatchTest:
+		out.Value = &op.Value
+	}
+	return json.Marshal(out)
+}
+
+func (op *PatchOperation) UnmarshalJSON(data []byte) error {
+	var in struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Path == nil {
+		return fmt.Errorf("green: patch operation %q is missing \"path\"", in.Op)
+	}
+
+	*op = PatchOperation{Op: in.Op, Path: *in.Path}
+	switch in.Op {
+	case PatchMove, PatchCopy:
+		if in.From == nil {
+			return fmt.Errorf("green: patch operation %q is missing \"from\"", in.Op)
+		}
+		op.From = *in.From
+	case PatchAdd, PatchReplace, PatchTest:
+		if in.Value == nil {
+			return fmt.Errorf("green: patch operation %q is missing \"value\"", in.Op)
+		}
+		return json.Unmarshal(in.Value, &op.Value)
+	case PatchRemove:
+	default:
+		return fmt.Errorf("green: unknown patch operation %q", in.Op)
+	}
+	return nil
+}
+
+func applyPatch(root Value, patch Patch) error {
+	for i, op := range patch {
+		if err := applyPatchOperation(root, op); err != nil {
+			return fmt.Errorf("green: patch operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func applyPatchOperation(root Value, op PatchOperation) error {
+	switch op.Op {
+	case PatchAdd:
+		return patchAdd(root, op.Path, op.Value)
+	case PatchRemove:
+		return deletePath(root, op.Path)
+	case PatchReplace:
+		return patchReplace(root, op.Path, op.Value)
+	case PatchMove:
+		if op.From == op.Path {
+			return nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("cannot move %q into one of its children", op.From)
+		}
+		v, err := patchGet(root, op.From)
+		if err != nil {
+			return err
+		}
+		if err := deletePath(root, op.From); err != nil {
+			return err
+		}
+		return patchAdd(root, op.Path, v)
+	case PatchCopy:
+		v, err := patchGet(root, op.From)
+		if err != nil {
+			return err
+		}
+		return patchAdd(root, op.Path, v)
+	case PatchTest:
+		v, err := patchGet(root, op.Path)
+		if err != nil {
+			return err
+		}
+		if !jsonEqual(v, op.Value) {
+			return ErrTestFailed
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// patchGet returns an immutable snapshot of the value at the pointer, so it can
+// be placed elsewhere in the tree without sharing a mutable container between
+// two parents.
+func patchGet(root Value, pointer string) (ImmutableValue, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	v, err := getPath(root, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return asImmutable(v), nil
+}
+
+func patchAdd(root Value, pointer string, val any) error {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return replaceRoot(root, val)
+	}
+
+	parent, err := resolveParent(root, tokens, false)
+	if err != nil {
+		return err
+	}
+	tok := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case *Map:
+		c.Set(tok, val)
+	case *Slice:
+		index, err := arrayIndex(tok, c.Len(), true)
+		if err != nil {
+			return newPathError(tokens, err)
+		}
+		if index == c.Len() {
+			c.Push(val)
+		} else {
+			c.Insert(index, val)
+		}
+	default:
+		return newPathError(tokens, wrongTypeError(parent, tok))
+	}
+	return nil
+}
+
+func patchReplace(root Value, pointer string, val any) error {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return replaceRoot(root, val)
+	}
+
+	parent, err := resolveParent(root, tokens, false)
+	if err != nil {
+		return err
+	}
+	tok := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case *Map:
+		if !c.Has(tok) {
+			return newPathError(tokens, fmt.Errorf("key %q %w", tok, ErrNotFound))
+		}
+		c.Set(tok, val)
+	case *Slice:
+		index, err := arrayIndex(tok, c.Len(), false)
+		if err != nil {
+			return newPathError(tokens, err)
+		}
+		c.Set(index, val)
+	default:
+		return newPathError(tokens, wrongTypeError(parent, tok))
+	}
+	return nil
+}
+
+// replaceRoot replaces the entire contents of the root container with the
+// given value, which must be a container of the same kind.
+func replaceRoot(root Value, val any) error {
+	switch c := root.(type) {
+	case *Map:
+		im, ok := asImmutable(val).(*ImmutableMap)
+		if !ok {
+			return &PathError{Err: fmt.Errorf("%w: cannot replace a map with %s", ErrWrongType, describeType(val))}
+		}
+		c.base = im
+		c.overwrites = nil
+		c.len = im.Len()
+		c.reportDirty()
+		if c.recording > 0 {
+			c.record(Mutation{Op: MutationReplace, Value: im}, nil)
+		}
+	case *Slice:
+		is, ok := asImmutable(val).(*ImmutableSlice)
+		if !ok {
+			return &PathError{Err: fmt.Errorf("%w: cannot replace a slice with %s", ErrWrongType, describeType(val))}
+		}
+		c.base = is
+		c.overwrites = nil
+		c.overwriteOffset = 0
+		c.prepends = nil
+		c.appends = nil
+		c.reportDirty()
+		if c.recording > 0 {
+			c.record(Mutation{Op: MutationReplace, Value: is}, nil)
+		}
+	}
+	return nil
+}
+
+// jsonEqual reports whether a and b represent the same JSON value. Unlike
+// Equal, numbers of different Go types are equal if their values are equal, as
+// required by the "test" operation.
+func jsonEqual(a, b any) bool {
+	a, b = asImmutable(a), asImmutable(b)
+	switch a := a.(type) {
+	case *ImmutableMap:
+		b, ok := b.(*ImmutableMap)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		for k, aValue := range a.All() {
+			bValue, ok := b.Get(k)
+			if !ok || !jsonEqual(aValue, bValue) {
+				return false
+			}
+		}
+		return true
+	case *ImmutableSlice:
+		b, ok := b.(*ImmutableSlice)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i, aValue := range a.All() {
+			if !jsonEqual(aValue, b.At(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	if aNum, ok := numberAsFloat64(a); ok {
+		bNum, ok := numberAsFloat64(b)
+		return ok && aNum == bNum
+	}
+	return Equal(a, b)
+}
+
+func numberAsFloat64(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,279 @@
+package green
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatch(t *testing.T) {
+	t.Run("RFC 6902 examples", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			doc    string
+			patch  string
+			expect string
+		}{
+			{
+				name:   "add object member",
+				doc:    `{"foo": "bar"}`,
+				patch:  `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+				expect: `{"baz": "qux", "foo": "bar"}`,
+			},
+			{
+				name:   "add array element",
+				doc:    `{"foo": ["bar", "baz"]}`,
+				patch:  `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+				expect: `{"foo": ["bar", "qux", "baz"]}`,
+			},
+			{
+				name:   "remove object member",
+				doc:    `{"baz": "qux", "foo": "bar"}`,
+				patch:  `[{"op": "remove", "path": "/baz"}]`,
+				expect: `{"foo": "bar"}`,
+			},
+			{
+				name:   "remove array element",
+				doc:    `{"foo": ["bar", "qux", "baz"]}`,
+				patch:  `[{"op": "remove", "path": "/foo/1"}]`,
+				expect: `{"foo": ["bar", "baz"]}`,
+			},
+			{
+				name:   "replace value",
+				doc:    `{"baz": "qux", "foo": "bar"}`,
+				patch:  `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+				expect: `{"baz": "boo", "foo": "bar"}`,
+			},
+			{
+				name:   "move value",
+				doc:    `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+				patch:  `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+				expect: `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+			},
+			{
+				name:   "move array element",
+				doc:    `{"foo": ["all", "grass", "cows", "eat"]}`,
+				patch:  `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+				expect: `{"foo": ["all", "cows", "eat", "grass"]}`,
+			},
+			{
+				name:   "test value success",
+				doc:    `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+				patch:  `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+				expect: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			},
+			{
+				name:   "add nested member",
+				doc:    `{"foo": "bar"}`,
+				patch:  `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+				expect: `{"foo": "bar", "child": {"grandchild": {}}}`,
+			},
+			{
+				name:   "add to end of array",
+				doc:    `{"foo": ["bar"]}`,
+				patch:  `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+				expect: `{"foo": ["bar", ["abc", "def"]]}`,
+			},
+			{
+				name:   "copy value",
+				doc:    `{"foo": {"bar": [1]}}`,
+				patch:  `[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "add", "path": "/baz/bar/-", "value": 2}]`,
+				expect: `{"foo": {"bar": [1]}, "baz": {"bar": [1, 2]}}`,
+			},
+			{
+				name:   "replace root",
+				doc:    `{"foo": "bar"}`,
+				patch:  `[{"op": "replace", "path": "", "value": {"baz": "qux"}}]`,
+				expect: `{"baz": "qux"}`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				im := NewImmutableMap(mustUnmarshalMap(t, tt.doc))
+				patch, err := ParsePatch([]byte(tt.patch))
+				require.NoError(t, err)
+
+				mut := im.Mutable()
+				require.NoError(t, mut.ApplyPatch(patch))
+				assert.Equal(t, mustUnmarshalMap(t, tt.expect), mut.Export())
+				assert.Equal(t, mustUnmarshalMap(t, tt.expect), mut.Immutable().Export())
+				assert.Equal(t, mustUnmarshalMap(t, tt.doc), im.Export())
+			})
+		}
+	})
+
+	t.Run("failures leave the Map unchanged", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			patch string
+			is    error
+		}{
+			{
+				name:  "test failure",
+				patch: `[{"op": "add", "path": "/a", "value": 1}, {"op": "test", "path": "/baz", "value": "bar"}]`,
+				is:    ErrTestFailed,
+			},
+			{
+				name:  "missing target",
+				patch: `[{"op": "remove", "path": "/foo/0"}, {"op": "replace", "path": "/missing", "value": 1}]`,
+				is:    ErrNotFound,
+			},
+			{
+				name:  "out of bounds",
+				patch: `[{"op": "add", "path": "/foo/3", "value": 1}]`,
+				is:    ErrNotFound,
+			},
+			{
+				name:  "wrong type",
+				patch: `[{"op": "add", "path": "/baz/x", "value": 1}]`,
+				is:    ErrWrongType,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				doc := `{"baz": "qux", "foo": ["a", "b"]}`
+				mut := NewImmutableMap(mustUnmarshalMap(t, doc)).Mutable()
+				foo := mustGetSliceFromMap(t, "foo", mut)
+
+				patch, err := ParsePatch([]byte(tt.patch))
+				require.NoError(t, err)
+				err = mut.ApplyPatch(patch)
+				assert.ErrorIs(t, err, tt.is)
+
+				assert.Equal(t, mustUnmarshalMap(t, doc), mut.Export())
+				assert.Equal(t, []any{"a", "b"}, foo.Export())
+			})
+		}
+
+		mut := NewImmutableMap(map[string]any{"a": map[string]any{"b": 1}}).Mutable()
+		err := mut.ApplyPatch(Patch{{Op: PatchMove, From: "/a", Path: "/a/c"}})
+		assert.Error(t, err)
+		err = mut.ApplyPatch(Patch{{Op: "frobnicate", Path: "/a"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("test compares numbers by value", func(t *testing.T) {
+		mut := NewImmutableMap(map[string]any{"n": 2, "m": map[string]any{"x": []any{int64(1)}}}).Mutable()
+		require.NoError(t, mut.ApplyPatch(Patch{
+			{Op: PatchTest, Path: "/n", Value: 2.0},
+			{Op: PatchTest, Path: "/m", Value: map[string]any{"x": []any{1.0}}},
+		}))
+		err := mut.ApplyPatch(Patch{{Op: PatchTest, Path: "/n", Value: "2"}})
+		assert.ErrorIs(t, err, ErrTestFailed)
+
+		// uncomparable values are compared deeply rather than panicking
+		mut = NewImmutableMap(map[string]any{"b": []byte("x"), "s": struct{ s []int }{}}).Mutable()
+		require.NoError(t, mut.ApplyPatch(Patch{
+			{Op: PatchTest, Path: "/b", Value: []byte("x")},
+			{Op: PatchTest, Path: "/s", Value: struct{ s []int }{}},
+		}))
+		err = mut.ApplyPatch(Patch{{Op: PatchTest, Path: "/b", Value: []byte("y")}})
+		assert.ErrorIs(t, err, ErrTestFailed)
+		err = mut.ApplyPatch(Patch{{Op: PatchTest, Path: "/s", Value: struct{ s []int }{s: []int{1}}}})
+		assert.ErrorIs(t, err, ErrTestFailed)
+	})
+
+	t.Run("CreatePatch round trip", func(t *testing.T) {
+		a := NewImmutableMap(map[string]any{
+			"keep":    map[string]any{"x": 1},
+			"change":  "old",
+			"remove":  true,
+			"list":    []any{1, 2, 3, 4},
+			"grow":    []any{1},
+			"retyped": map[string]any{"k": "v"},
+		})
+		mut := a.Mutable()
+		mut.Set("change", "new")
+		mut.Delete("remove")
+		mut.Set("added", []any{"x"})
+		mut.Set("retyped", "scalar")
+		require.NoError(t, mut.DeletePath("/list/1"))
+		require.NoError(t, mut.DeletePath("/list/1"))
+		require.NoError(t, mut.SetPath("/grow/-", 2))
+		require.NoError(t, mut.SetPath("/grow/-", map[string]any{"n": 3}))
+		b := mut.Immutable()
+
+		patch := CreatePatch(a, b)
+		target := a.Mutable()
+		require.NoError(t, target.ApplyPatch(patch))
+		assert.True(t, Equal(b, target), "%v", target.Export())
+
+		// also through JSON
+		data, err := json.Marshal(patch)
+		require.NoError(t, err)
+		decoded, err := ParsePatch(data)
+		require.NoError(t, err)
+		target = a.Mutable()
+		require.NoError(t, target.ApplyPatch(decoded))
+		assert.Equal(t, mustUnmarshalMap(t, string(mustMarshal(t, b))), mustUnmarshalMap(t, string(mustMarshal(t, target))))
+	})
+
+	t.Run("Slice.ApplyPatch", func(t *testing.T) {
+		is := NewImmutableSlice([]any{"a", map[string]any{"k": "v"}})
+		mut := is.Mutable()
+		require.NoError(t, mut.ApplyPatch(Patch{
+			{Op: PatchAdd, Path: "/0", Value: "first"},
+			{Op: PatchReplace, Path: "/2/k", Value: "w"},
+			{Op: PatchCopy, From: "/2", Path: "/-"},
+		}))
+		expect := []any{"first", "a", map[string]any{"k": "w"}, map[string]any{"k": "w"}}
+		assert.Equal(t, expect, mut.Export())
+		assert.Equal(t, []any{"a", map[string]any{"k": "v"}}, is.Export())
+
+		err := mut.ApplyPatch(Patch{{Op: PatchReplace, Path: "", Value: "nope"}})
+		assert.ErrorIs(t, err, ErrWrongType)
+		assert.Equal(t, expect, mut.Export())
+	})
+
+	t.Run("PatchOperation JSON", func(t *testing.T) {
+		data, err := json.Marshal(Patch{
+			{Op: PatchAdd, Path: "/a", Value: nil},
+			{Op: PatchRemove, Path: "/b"},
+			{Op: PatchMove, From: "", Path: "/c"},
+			{Op: PatchTest, Path: "/d", Value: NewImmutableSlice([]any{1})},
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"op": "add", "path": "/a", "value": null},
+			{"op": "remove", "path": "/b"},
+			{"op": "move", "from": "", "path": "/c"},
+			{"op": "test", "path": "/d", "value": [1]}
+		]`, string(data))
+
+		for _, bad := range []string{
+			`[{"op": "add", "path": "/a"}]`,
+			`[{"op": "move", "path": "/a"}]`,
+			`[{"op": "remove"}]`,
+			`[{"op": "frobnicate", "path": "/a"}]`,
+		} {
+			_, err := ParsePatch([]byte(bad))
+			assert.Error(t, err, bad)
+		}
+	})
+
+	t.Run("errors identify the operation", func(t *testing.T) {
+		mut := NewImmutableMap(map[string]any{}).Mutable()
+		err := mut.ApplyPatch(Patch{{Op: PatchRemove, Path: "/x"}})
+		var pathErr *PathError
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/x", pathErr.Path)
+		assert.Contains(t, err.Error(), "patch operation 0")
+	})
+}
+
+func mustUnmarshalMap(t *testing.T, s string) map[string]any {
+	var m map[string]any
+	require.NoError(t, json.Unmarshal([]byte(s), &m))
+	return m
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
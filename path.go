@@ -0,0 +1,431 @@
+package green
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPointer indicates that a string is not a valid JSON Pointer as
+	// defined by RFC 6901.
+	ErrInvalidPointer = errors.New("invalid JSON pointer")
+
+	// ErrNotFound indicates that a key or index referenced by a path does not
+	// exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrWrongType indicates that a value along a path does not have the type
+	// required to continue, e.g. a key lookup on a string.
+	ErrWrongType = errors.New("wrong type")
+)
+
+// PathError records a failure to resolve a JSON Pointer against a container.
+// Its Err field wraps one of ErrInvalidPointer, ErrNotFound, or ErrWrongType,
+// which can be checked for with errors.Is.
+type PathError struct {
+	// Path is the JSON Pointer prefix up to and including the segment at which
+	// resolution failed.
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("green: path %q: %v", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// PathOption configures the behavior of path-based writes such as
+// Map.SetPath.
+type PathOption func(*pathConfig)
+
+type pathConfig struct {
+	createMissing bool
+}
+
+// WithCreateMissing makes path-based writes create missing intermediate
+// containers instead of failing with ErrNotFound. A missing intermediate is
+// created as a Slice if the segment following it is "-", and as a Map
+// otherwise. Existing values of the wrong type are never replaced.
+func WithCreateMissing() PathOption {
+	return func(c *pathConfig) {
+		c.createMissing = true
+	}
+}
+
+// GetPath retrieves the ImmutableValue referenced by the given JSON Pointer
+// (RFC 6901), e.g. "/tricks/1". The empty pointer "" refers to the
+// ImmutableMap itself. The values returned are the same as those returned by
+// Get and At on the containers along the path. If a segment cannot be
+// resolved, a *PathError is returned.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (m *ImmutableMap) GetPath(pointer string) (ImmutableValue, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return getPathImmutable(m, tokens)
+}
+
+// GetPath retrieves the ImmutableValue referenced by the given JSON Pointer
+// (RFC 6901), e.g. "/1/name". The empty pointer "" refers to the
+// ImmutableSlice itself. The values returned are the same as those returned by
+// Get and At on the containers along the path. If a segment cannot be
+// resolved, a *PathError is returned.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (s *ImmutableSlice) GetPath(pointer string) (ImmutableValue, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return getPathImmutable(s, tokens)
+}
+
+// GetPath retrieves the Value referenced by the given JSON Pointer (RFC 6901),
+// e.g. "/tricks/1". The empty pointer "" refers to the Map itself. The values
+// returned are the same as those returned by Get and At on the containers along
+// the path, so nested containers are wrapped as *green.Map and *green.Slice. If
+// a segment cannot be resolved, a *PathError is returned.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (m *Map) GetPath(pointer string) (Value, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return getPath(m, tokens)
+}
+
+// GetPath retrieves the Value referenced by the given JSON Pointer (RFC 6901),
+// e.g. "/1/name". The empty pointer "" refers to the Slice itself. The values
+// returned are the same as those returned by Get and At on the containers along
+// the path, so nested containers are wrapped as *green.Map and *green.Slice. If
+// a segment cannot be resolved, a *PathError is returned.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (s *Slice) GetPath(pointer string) (Value, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return getPath(s, tokens)
+}
+
+// SetPath sets the value referenced by the given JSON Pointer (RFC 6901). The
+// final segment is handled like Set on the container it refers into: for a Map
+// the key is set, and for a Slice the element at the index is replaced, or the
+// value is pushed if the index is the length of the Slice or "-". Only the
+// containers along the path are marked dirty, so sibling subtrees keep sharing
+// the underlying immutable values. If the path cannot be resolved, a *PathError
+// is returned and the Map is not modified. If the Map is nil, this panics.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (m *Map) SetPath(pointer string, val any, opts ...PathOption) error {
+	if m == nil {
+		panic("*green.Map.SetPath: assignment to entry in nil map")
+	}
+	return setPath(m, pointer, val, opts)
+}
+
+// DeletePath removes the value referenced by the given JSON Pointer (RFC 6901).
+// If the final segment refers to a Map key, the key is deleted; if it refers to
+// a Slice element, the element is removed and subsequent elements shift left.
+// Unlike Delete, a missing key is reported as an error wrapping ErrNotFound.
+// If the path cannot be resolved, a *PathError is returned and the Map is not
+// modified.
+//
+// This has O(d) average time complexity when deleting a Map key, where d is
+// the number of segments in the pointer.
+func (m *Map) DeletePath(pointer string) error {
+	return deletePath(m, pointer)
+}
+
+// SetPath sets the value referenced by the given JSON Pointer (RFC 6901). See
+// Map.SetPath for details. If the Slice is nil, this panics.
+//
+// This has O(d) average time complexity, where d is the number of segments in
+// the pointer.
+func (s *Slice) SetPath(pointer string, val any, opts ...PathOption) error {
+	if s == nil {
+		panic("*green.Slice.SetPath: assignment to element in nil slice")
+	}
+	return setPath(s, pointer, val, opts)
+}
+
+// DeletePath removes the value referenced by the given JSON Pointer (RFC 6901).
+// See Map.DeletePath for details.
+//
+// This has O(d) average time complexity when deleting a Map key, where d is
+// the number of segments in the pointer.
+func (s *Slice) DeletePath(pointer string) error {
+	return deletePath(s, pointer)
+}
+
+func setPath(root Value, pointer string, val any, opts []PathOption) error {
+	var cfg pathConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return &PathError{Path: pointer, Err: fmt.Errorf("%w: cannot set the root", ErrWrongType)}
+	}
+
+	parent, err := resolveParent(root, tokens, cfg.createMissing)
+	if err != nil {
+		return err
+	}
+	return setChild(parent, tokens, val)
+}
+
+func deletePath(root Value, pointer string) error {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return &PathError{Path: pointer, Err: fmt.Errorf("%w: cannot delete the root", ErrWrongType)}
+	}
+
+	parent, err := resolveParent(root, tokens, false)
+	if err != nil {
+		return err
+	}
+	return deleteChild(parent, tokens)
+}
+
+// resolveParent walks all but the last token, returning the container the last
+// token refers into. If create is true, missing intermediate containers are
+// created along the way.
+func resolveParent(root Value, tokens []string, create bool) (Value, error) {
+	v := root
+	for i, tok := range tokens[:len(tokens)-1] {
+		switch c := v.(type) {
+		case *Map:
+			next, ok := c.Get(tok)
+			if !ok {
+				if !create {
+					return nil, newPathError(tokens[:i+1], fmt.Errorf("key %q %w", tok, ErrNotFound))
+				}
+				c.Set(tok, newContainerFor(tokens[i+1]))
+				next, _ = c.Get(tok)
+			}
+			v = next
+		case *Slice:
+			index, err := arrayIndex(tok, c.Len(), create)
+			if err != nil {
+				return nil, newPathError(tokens[:i+1], err)
+			}
+			if index == c.Len() {
+				c.Push(newContainerFor(tokens[i+1]))
+			}
+			v = c.At(index)
+		default:
+			return nil, newPathError(tokens[:i+1], wrongTypeError(v, tok))
+		}
+	}
+	return v, nil
+}
+
+// newContainerFor returns an empty native container suitable for holding the
+// given next reference token. Native containers are used so that the parent
+// wraps them, linking the new container into the dirty tracking chain.
+func newContainerFor(nextTok string) any {
+	if nextTok == "-" {
+		return []any{}
+	}
+	return map[string]any{}
+}
+
+func setChild(parent Value, tokens []string, val any) error {
+	tok := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case *Map:
+		c.Set(tok, val)
+	case *Slice:
+		index, err := arrayIndex(tok, c.Len(), true)
+		if err != nil {
+			return newPathError(tokens, err)
+		}
+		if index == c.Len() {
+			c.Push(val)
+		} else {
+			c.Set(index, val)
+		}
+	default:
+		return newPathError(tokens, wrongTypeError(parent, tok))
+	}
+	return nil
+}
+
+func deleteChild(parent Value, tokens []string) error {
+	tok := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case *Map:
+		if !c.Has(tok) {
+			return newPathError(tokens, fmt.Errorf("key %q %w", tok, ErrNotFound))
+		}
+		c.Delete(tok)
+	case *Slice:
+		index, err := arrayIndex(tok, c.Len(), false)
+		if err != nil {
+			return newPathError(tokens, err)
+		}
+		c.Remove(index)
+	default:
+		return newPathError(tokens, wrongTypeError(parent, tok))
+	}
+	return nil
+}
+
+func getPathImmutable(v ImmutableValue, tokens []string) (ImmutableValue, error) {
+	for i, tok := range tokens {
+		switch c := v.(type) {
+		case *ImmutableMap:
+			next, ok := c.Get(tok)
+			if !ok {
+				return nil, newPathError(tokens[:i+1], fmt.Errorf("key %q %w", tok, ErrNotFound))
+			}
+			v = next
+		case *ImmutableSlice:
+			index, err := arrayIndex(tok, c.Len(), false)
+			if err != nil {
+				return nil, newPathError(tokens[:i+1], err)
+			}
+			v = c.At(index)
+		default:
+			return nil, newPathError(tokens[:i+1], wrongTypeError(v, tok))
+		}
+	}
+	return v, nil
+}
+
+func getPath(v Value, tokens []string) (Value, error) {
+	for i, tok := range tokens {
+		switch c := v.(type) {
+		case *Map:
+			next, ok := c.Get(tok)
+			if !ok {
+				return nil, newPathError(tokens[:i+1], fmt.Errorf("key %q %w", tok, ErrNotFound))
+			}
+			v = next
+		case *Slice:
+			index, err := arrayIndex(tok, c.Len(), false)
+			if err != nil {
+				return nil, newPathError(tokens[:i+1], err)
+			}
+			v = c.At(index)
+		default:
+			return nil, newPathError(tokens[:i+1], wrongTypeError(v, tok))
+		}
+	}
+	return v, nil
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, &PathError{
+			Path: pointer,
+			Err:  fmt.Errorf("%w: must be empty or start with '/'", ErrInvalidPointer),
+		}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, tok := range tokens {
+		if !strings.Contains(tok, "~") {
+			continue
+		}
+		for j := 0; j < len(tok); j++ {
+			if tok[j] == '~' && (j+1 == len(tok) || (tok[j+1] != '0' && tok[j+1] != '1')) {
+				return nil, &PathError{
+					Path: pointer,
+					Err:  fmt.Errorf("%w: bad escape sequence in %q", ErrInvalidPointer, tok),
+				}
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// formatPointer is the inverse of parsePointer.
+func formatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, tok := range tokens {
+		b.WriteByte('/')
+		b.WriteString(escapePointerToken(tok))
+	}
+	return b.String()
+}
+
+func escapePointerToken(tok string) string {
+	if !strings.ContainsAny(tok, "~/") {
+		return tok
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(tok, "~", "~0"), "/", "~1")
+}
+
+// arrayIndex parses a reference token as an index into an array of the given
+// length. If allowEnd is true, the index may equal length, and the special
+// token "-" resolves to length.
+func arrayIndex(tok string, length int, allowEnd bool) (int, error) {
+	if tok == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("index %q %w (length %d)", tok, ErrNotFound, length)
+	}
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') || tok[0] == '+' {
+		return 0, fmt.Errorf("index %q %w: not a valid array index", tok, ErrNotFound)
+	}
+	index, err := strconv.Atoi(tok)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("index %q %w: not a valid array index", tok, ErrNotFound)
+	}
+	if index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("index %d %w (length %d)", index, ErrNotFound, length)
+	}
+	return index, nil
+}
+
+func newPathError(tokens []string, err error) *PathError {
+	return &PathError{Path: formatPointer(tokens), Err: err}
+}
+
+func wrongTypeError(v any, tok string) error {
+	return fmt.Errorf("%w: cannot look up %q in %s", ErrWrongType, tok, describeType(v))
+}
+
+// describeType returns a short, human-readable name for the type of v for use
+// in error messages.
+func describeType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case *ImmutableMap, *Map, map[string]any:
+		return "map"
+	case *ImmutableSlice, *Slice, []any:
+		return "slice"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,257 @@
+package green
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed":  "Great Pyrenees",
+			"tricks": []any{"sit", "shake", map[string]any{"name": "roll"}},
+			"owner": map[string]any{
+				"name": "Sam",
+				"a/b":  "slash",
+				"m~n":  "tilde",
+				"":     "empty",
+			},
+		}
+	}
+
+	t.Run("parsePointer", func(t *testing.T) {
+		tokens, err := parsePointer("")
+		require.NoError(t, err)
+		assert.Empty(t, tokens)
+
+		tokens, err = parsePointer("/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{""}, tokens)
+
+		tokens, err = parsePointer("/a~1b/m~0n/~01")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a/b", "m~n", "~1"}, tokens)
+		assert.Equal(t, "/a~1b/m~0n/~01", formatPointer(tokens))
+
+		for _, bad := range []string{"a", "/a~", "/a~2"} {
+			_, err = parsePointer(bad)
+			assert.ErrorIs(t, err, ErrInvalidPointer, bad)
+		}
+	})
+
+	t.Run("ImmutableMap.GetPath", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+
+		v, err := im.GetPath("/tricks/1")
+		require.NoError(t, err)
+		assert.Equal(t, "shake", v)
+
+		v, err = im.GetPath("/tricks/2/name")
+		require.NoError(t, err)
+		assert.Equal(t, "roll", v)
+
+		v, err = im.GetPath("/owner/a~1b")
+		require.NoError(t, err)
+		assert.Equal(t, "slash", v)
+		v, err = im.GetPath("/owner/m~0n")
+		require.NoError(t, err)
+		assert.Equal(t, "tilde", v)
+		v, err = im.GetPath("/owner/")
+		require.NoError(t, err)
+		assert.Equal(t, "empty", v)
+
+		// same instances as Get
+		tricks, err := im.GetPath("/tricks")
+		require.NoError(t, err)
+		tricksGet, ok := im.Get("tricks")
+		require.True(t, ok)
+		assert.Same(t, tricksGet, tricks)
+
+		root, err := im.GetPath("")
+		require.NoError(t, err)
+		assert.Same(t, im, root)
+	})
+
+	t.Run("ImmutableSlice.GetPath", func(t *testing.T) {
+		is := NewImmutableSlice([]any{"a", map[string]any{"b": []any{1, 2}}})
+
+		v, err := is.GetPath("/1/b/1")
+		require.NoError(t, err)
+		assert.Equal(t, 2, v)
+
+		v, err = is.GetPath("/0")
+		require.NoError(t, err)
+		assert.Equal(t, "a", v)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+
+		_, err := im.GetPath("/missing/x")
+		assert.ErrorIs(t, err, ErrNotFound)
+		var pathErr *PathError
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/missing", pathErr.Path)
+
+		_, err = im.GetPath("/tricks/3")
+		assert.ErrorIs(t, err, ErrNotFound)
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/tricks/3", pathErr.Path)
+
+		for _, bad := range []string{"/tricks/-", "/tricks/01", "/tricks/x", "/tricks/-1", "/tricks/+1"} {
+			_, err = im.GetPath(bad)
+			assert.ErrorIs(t, err, ErrNotFound, bad)
+		}
+
+		_, err = im.GetPath("/breed/x")
+		assert.ErrorIs(t, err, ErrWrongType)
+		require.True(t, errors.As(err, &pathErr))
+		assert.Equal(t, "/breed/x", pathErr.Path)
+
+		_, err = im.GetPath("tricks")
+		assert.ErrorIs(t, err, ErrInvalidPointer)
+	})
+
+	t.Run("Map.GetPath and Slice.GetPath", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+
+		v, err := mut.GetPath("/tricks/2")
+		require.NoError(t, err)
+		trick, ok := v.(*Map)
+		require.True(t, ok, "%T", v)
+		assert.Same(t, mustGetMapFromSlice(t, 2, mustGetSliceFromMap(t, "tricks", mut)), trick)
+
+		trick.Set("name", "play dead")
+		v, err = mut.GetPath("/tricks/2/name")
+		require.NoError(t, err)
+		assert.Equal(t, "play dead", v)
+
+		tricks := mustGetSliceFromMap(t, "tricks", mut)
+		tricks.Push("speak")
+		v, err = tricks.GetPath("/3")
+		require.NoError(t, err)
+		assert.Equal(t, "speak", v)
+
+		_, err = mut.GetPath("/tricks/4")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// the immutable is unaffected
+		v, err = im.GetPath("/tricks/2/name")
+		require.NoError(t, err)
+		assert.Equal(t, "roll", v)
+	})
+
+	t.Run("Map.SetPath", func(t *testing.T) {
+		source := newSource()
+		sourceOriginal := deepCopy(source)
+		im := NewImmutableMap(source)
+		mut := im.Mutable()
+
+		require.NoError(t, mut.SetPath("/owner/name", "Alex"))
+		require.NoError(t, mut.SetPath("/tricks/0", "stay"))
+		require.NoError(t, mut.SetPath("/tricks/-", "speak"))
+		require.NoError(t, mut.SetPath("/tricks/4", "beg"))
+		require.NoError(t, mut.SetPath("/tricks/2/name", "play dead"))
+		require.NoError(t, mut.SetPath("/age", 6))
+
+		expect := newSource()
+		expect["owner"].(map[string]any)["name"] = "Alex"
+		expect["tricks"] = []any{"stay", "shake", map[string]any{"name": "play dead"}, "speak", "beg"}
+		expect["age"] = 6
+		assert.Equal(t, expect, mut.Export())
+		assert.Equal(t, expect, mut.Immutable().Export())
+		assert.Equal(t, sourceOriginal, im.Export())
+		assert.Equal(t, sourceOriginal, source)
+
+		err := mut.SetPath("/missing/name", "x")
+		assert.ErrorIs(t, err, ErrNotFound)
+		err = mut.SetPath("/tricks/9", "x")
+		assert.ErrorIs(t, err, ErrNotFound)
+		err = mut.SetPath("/breed/name", "x")
+		assert.ErrorIs(t, err, ErrWrongType)
+		err = mut.SetPath("", "x")
+		assert.ErrorIs(t, err, ErrWrongType)
+		assert.Equal(t, expect, mut.Export())
+	})
+
+	t.Run("Map.SetPath only copies the touched branch", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+		require.NoError(t, mut.SetPath("/tricks/2/name", "play dead"))
+
+		im2 := mut.Immutable()
+		owner1, ok := im.Get("owner")
+		require.True(t, ok)
+		owner2, ok := im2.Get("owner")
+		require.True(t, ok)
+		assert.Same(t, owner1, owner2)
+
+		tricks1, ok := im.Get("tricks")
+		require.True(t, ok)
+		tricks2, ok := im2.Get("tricks")
+		require.True(t, ok)
+		assert.NotSame(t, tricks1, tricks2)
+	})
+
+	t.Run("Map.SetPath with WithCreateMissing", func(t *testing.T) {
+		mut := NewImmutableMap(map[string]any{"breed": "Great Pyrenees"}).Mutable()
+
+		require.NoError(t, mut.SetPath("/owner/address/city", "Bern", WithCreateMissing()))
+		require.NoError(t, mut.SetPath("/vets/-/name", "Dr. Who", WithCreateMissing()))
+		require.NoError(t, mut.SetPath("/vets/-/name", "Dr. No", WithCreateMissing()))
+
+		err := mut.SetPath("/breed/origin", "France", WithCreateMissing())
+		assert.ErrorIs(t, err, ErrWrongType)
+
+		expect := map[string]any{
+			"breed": "Great Pyrenees",
+			"owner": map[string]any{"address": map[string]any{"city": "Bern"}},
+			"vets": []any{
+				map[string]any{"name": "Dr. Who"},
+				map[string]any{"name": "Dr. No"},
+			},
+		}
+		assert.Equal(t, expect, mut.Export())
+		assert.Equal(t, expect, mut.Immutable().Export())
+	})
+
+	t.Run("Map.DeletePath", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+
+		require.NoError(t, mut.DeletePath("/owner/a~1b"))
+		require.NoError(t, mut.DeletePath("/tricks/1"))
+		require.NoError(t, mut.DeletePath("/breed"))
+
+		expect := newSource()
+		delete(expect, "breed")
+		delete(expect["owner"].(map[string]any), "a/b")
+		expect["tricks"] = []any{"sit", map[string]any{"name": "roll"}}
+		assert.Equal(t, expect, mut.Export())
+		assert.Equal(t, expect, mut.Immutable().Export())
+		assert.Equal(t, newSource(), im.Export())
+
+		assert.ErrorIs(t, mut.DeletePath("/breed"), ErrNotFound)
+		assert.ErrorIs(t, mut.DeletePath("/tricks/2"), ErrNotFound)
+		assert.ErrorIs(t, mut.DeletePath(""), ErrWrongType)
+	})
+
+	t.Run("Slice.SetPath and Slice.DeletePath", func(t *testing.T) {
+		is := NewImmutableSlice([]any{"a", map[string]any{"b": []any{1, 2}}, "c"})
+		mut := is.Mutable()
+		mut.PushFront("front")
+
+		require.NoError(t, mut.SetPath("/2/b/0", 100))
+		require.NoError(t, mut.SetPath("/-", "end"))
+		require.NoError(t, mut.DeletePath("/1"))
+
+		expect := []any{"front", map[string]any{"b": []any{100, 2}}, "c", "end"}
+		assert.Equal(t, expect, mut.Export())
+		assert.Equal(t, expect, mut.Immutable().Export())
+		assert.Equal(t, []any{"a", map[string]any{"b": []any{1, 2}}, "c"}, is.Export())
+	})
+}
@@ -0,0 +1,353 @@
+package green
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// MutationOp identifies the kind of a recorded Mutation.
+type MutationOp int
+
+// The kinds of mutations recorded in a MutationLog.
+const (
+	// MutationSet records Map.Set or Slice.Set.
+	MutationSet MutationOp = iota + 1
+	// MutationDelete records Map.Delete of an existing key.
+	MutationDelete
+	// MutationPush records Slice.Push.
+	MutationPush
+	// MutationPushFront records Slice.PushFront.
+	MutationPushFront
+	// MutationReSlice records Slice.ReSlice.
+	MutationReSlice
+	// MutationReplace records the wholesale replacement of a container's
+	// contents, e.g. by UnmarshalJSON.
+	MutationReplace
+	// MutationSplice records Slice.Splice, and the Slice methods built on
+	// it: Insert, Remove, RemoveRange, Pop, and PopFront.
+	MutationSplice
+)
+
+func (o MutationOp) String() string {
+	switch o {
+	case MutationSet:
+		return "set"
+	case MutationDelete:
+		return "delete"
+	case MutationPush:
+		return "push"
+	case MutationPushFront:
+		return "push-front"
+	case MutationReSlice:
+		return "reslice"
+	case MutationReplace:
+		return "replace"
+	case MutationSplice:
+		return "splice"
+	default:
+		return fmt.Sprintf("MutationOp(%d)", int(o))
+	}
+}
+
+type (
+	// Mutation is a single operation recorded in a MutationLog.
+	Mutation struct {
+		Op MutationOp
+		// Path is the JSON Pointer (RFC 6901), relative to the recording
+		// container, of the key or index written by MutationSet and
+		// MutationDelete, and of the mutated container otherwise.
+		Path string
+		// Value is an immutable snapshot of the value written by MutationSet,
+		// MutationPush, MutationPushFront, and MutationReplace. For
+		// MutationSplice, it is an *ImmutableSlice of the values inserted.
+		Value ImmutableValue
+		// Left and Right are the bounds passed to ReSlice for MutationReSlice,
+		// and the bounds of the replaced range for MutationSplice.
+		Left, Right int
+	}
+
+	// MutationLog is a record of the mutations performed on a Map or Slice and
+	// on the containers nested within it. See Map.StartRecording.
+	//
+	// The methods for MutationLog are NOT SAFE for concurrent use with each
+	// other or with mutations of the recording container.
+	MutationLog struct {
+		mutations []Mutation
+	}
+)
+
+// StartRecording starts recording the mutations performed on the Map, including
+// writes to nested containers obtained from it, into the returned MutationLog.
+// Paths are recorded relative to the Map. If the Map is already recording, its
+// existing MutationLog is returned. If the Map is nil, this panics.
+//
+// Only mutations that go through Map and Slice methods are recorded. Values
+// are recorded as immutable snapshots, so later mutations of a Map or Slice
+// passed to Set are not reflected in the log.
+//
+// This has O(k) time complexity, where k is the number of nested containers
+// which have been obtained from the Map so far. While recording, each mutation
+// of a nested container costs O(w) per ancestor, where w is the number of
+// values written to that ancestor.
+func (m *Map) StartRecording() *MutationLog {
+	if m == nil {
+		panic("*green.Map.StartRecording: record nil map")
+	}
+	if m.log == nil {
+		m.log = &MutationLog{}
+		m.adjustRecording(1)
+	}
+	return m.log
+}
+
+// StopRecording stops recording mutations and returns the MutationLog, or nil
+// if the Map was not recording.
+//
+// This has O(k) time complexity, where k is the number of nested containers
+// which have been obtained from the Map so far.
+func (m *Map) StopRecording() *MutationLog {
+	if m == nil || m.log == nil {
+		return nil
+	}
+	log := m.log
+	m.log = nil
+	m.adjustRecording(-1)
+	return log
+}
+
+// StartRecording is like Map.StartRecording, but for a Slice. If the Slice is
+// nil, this panics.
+//
+// This has the same time complexity as Map.StartRecording.
+func (s *Slice) StartRecording() *MutationLog {
+	if s == nil {
+		panic("*green.Slice.StartRecording: record nil slice")
+	}
+	if s.log == nil {
+		s.log = &MutationLog{}
+		s.adjustRecording(1)
+	}
+	return s.log
+}
+
+// StopRecording is like Map.StopRecording, but for a Slice.
+//
+// This has the same time complexity as Map.StopRecording.
+func (s *Slice) StopRecording() *MutationLog {
+	if s == nil || s.log == nil {
+		return nil
+	}
+	log := s.log
+	s.log = nil
+	s.adjustRecording(-1)
+	return log
+}
+
+// Mutations returns the recorded mutations in the order they were performed.
+func (l *MutationLog) Mutations() []Mutation {
+	if l == nil {
+		return nil
+	}
+	return slices.Clone(l.mutations)
+}
+
+// Len returns the number of recorded mutations.
+func (l *MutationLog) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.mutations)
+}
+
+// Replay performs the recorded mutations on the target, which must be a *Map
+// or *Slice. The target is expected to be derived from the same value as the
+// recording container was when recording started, in which case it ends up
+// equal to the recording container. Either all mutations are performed or, if
+// any fails, an error is returned and the target is left unchanged.
+//
+// This has O(m*d) average time complexity, where m is the number of
+// mutations and d is the depth of their paths.
+func (l *MutationLog) Replay(target Value) error {
+	var scratch Value
+	switch t := target.(type) {
+	case *Map:
+		scratch = t.Immutable().Mutable()
+	case *Slice:
+		scratch = t.Immutable().Mutable()
+	default:
+		return &PathError{Err: fmt.Errorf("%w: cannot replay onto %s", ErrWrongType, describeType(target))}
+	}
+	// dry run against a scratch copy so a failing mutation can't leave the
+	// target partially mutated
+	if err := l.replay(scratch); err != nil {
+		return err
+	}
+	return l.replay(target)
+}
+
+func (l *MutationLog) replay(target Value) error {
+	for i, mut := range l.Mutations() {
+		if err := replayMutation(target, mut); err != nil {
+			return fmt.Errorf("green: mutation %d (%s %q): %w", i, mut.Op, mut.Path, err)
+		}
+	}
+	return nil
+}
+
+func replayMutation(root Value, mut Mutation) error {
+	tokens, err := parsePointer(mut.Path)
+	if err != nil {
+		return err
+	}
+
+	switch mut.Op {
+	case MutationSet, MutationDelete:
+		if len(tokens) == 0 {
+			return newPathError(tokens, fmt.Errorf("%w: %s requires a key or index", ErrWrongType, mut.Op))
+		}
+		parent, err := resolveParent(root, tokens, false)
+		if err != nil {
+			return err
+		}
+		if mut.Op == MutationDelete {
+			return deleteChild(parent, tokens)
+		}
+		return setChild(parent, tokens, mut.Value)
+	}
+
+	v, err := getPath(root, tokens)
+	if err != nil {
+		return err
+	}
+	if mut.Op == MutationReplace {
+		return replaceRoot(v, mut.Value)
+	}
+	s, ok := v.(*Slice)
+	if !ok {
+		return newPathError(tokens, fmt.Errorf("%w: cannot %s %s", ErrWrongType, mut.Op, describeType(v)))
+	}
+	switch mut.Op {
+	case MutationPush:
+		s.Push(mut.Value)
+	case MutationPushFront:
+		s.PushFront(mut.Value)
+	case MutationReSlice:
+		if mut.Left < 0 || mut.Right > s.Len() || mut.Left > mut.Right {
+			return newPathError(tokens, fmt.Errorf("slice bounds [%d:%d] %w (length %d)", mut.Left, mut.Right, ErrNotFound, s.Len()))
+		}
+		s.ReSlice(mut.Left, mut.Right)
+	case MutationSplice:
+		if mut.Left < 0 || mut.Right > s.Len() || mut.Left > mut.Right {
+			return newPathError(tokens, fmt.Errorf("slice bounds [%d:%d] %w (length %d)", mut.Left, mut.Right, ErrNotFound, s.Len()))
+		}
+		inserted, _ := mut.Value.(*ImmutableSlice)
+		vals := make([]any, 0, inserted.Len())
+		for _, v := range inserted.All() {
+			vals = append(vals, v)
+		}
+		s.Splice(mut.Left, mut.Right, vals...)
+	default:
+		return fmt.Errorf("unknown mutation %s", mut.Op)
+	}
+	return nil
+}
+
+// record records a mutation at the given path relative to the Map into its
+// MutationLog, if any, and those of its recording ancestors.
+func (m *Map) record(mut Mutation, tokens []string) {
+	if m.log != nil {
+		mut.Path = formatPointer(tokens)
+		m.log.mutations = append(m.log.mutations, mut)
+	}
+	for _, p := range m.parents {
+		p.recordFrom(m, mut, tokens)
+	}
+}
+
+func (m *Map) recordFrom(child any, mut Mutation, tokens []string) {
+	if m.recording == 0 {
+		return
+	}
+	for k, v := range m.overwrites {
+		if v == child {
+			m.record(mut, append([]string{k}, tokens...))
+			return
+		}
+	}
+	// the child has since been replaced or deleted
+}
+
+func (m *Map) recorders() int {
+	return m.recording
+}
+
+// adjustRecording adds delta to the number of recording containers at or
+// above the Map and all nested containers obtained from it.
+func (m *Map) adjustRecording(delta int) {
+	m.recording += delta
+	for _, v := range m.overwrites {
+		adjustRecording(v, delta)
+	}
+}
+
+func (s *Slice) record(mut Mutation, tokens []string) {
+	if s.log != nil {
+		mut.Path = formatPointer(tokens)
+		s.log.mutations = append(s.log.mutations, mut)
+	}
+	for _, p := range s.parents {
+		p.recordFrom(s, mut, tokens)
+	}
+}
+
+func (s *Slice) recordFrom(child any, mut Mutation, tokens []string) {
+	if s.recording == 0 {
+		return
+	}
+	index := -1
+	if i := slices.IndexFunc(s.prepends, func(v any) bool { return v == child }); i >= 0 {
+		index = s.prependIndex(i)
+	} else if i := slices.IndexFunc(s.appends, func(v any) bool { return v == child }); i >= 0 {
+		index = len(s.prepends) + s.base.Len() + i
+	} else {
+		for k, v := range s.overwrites {
+			i := k - s.overwriteOffset
+			if v == child && i >= 0 && i < s.base.Len() {
+				index = len(s.prepends) + i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		// the child has since been replaced or sliced away
+		return
+	}
+	s.record(mut, append([]string{strconv.Itoa(index)}, tokens...))
+}
+
+func (s *Slice) recorders() int {
+	return s.recording
+}
+
+func (s *Slice) adjustRecording(delta int) {
+	s.recording += delta
+	for _, v := range s.prepends {
+		adjustRecording(v, delta)
+	}
+	for _, v := range s.overwrites {
+		adjustRecording(v, delta)
+	}
+	for _, v := range s.appends {
+		adjustRecording(v, delta)
+	}
+}
+
+func adjustRecording(v any, delta int) {
+	switch v := v.(type) {
+	case *Map:
+		v.adjustRecording(delta)
+	case *Slice:
+		v.adjustRecording(delta)
+	}
+}
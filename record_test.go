@@ -0,0 +1,116 @@
+package green
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutationLog(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed":  "Great Pyrenees",
+			"tricks": []any{"sit", "shake", map[string]any{"name": "roll"}},
+			"owner":  map[string]any{"name": "Sam", "a/b": "slash"},
+		}
+	}
+
+	t.Run("records nested writes with their paths", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+
+		// obtained before recording starts
+		owner := mustGetMapFromMap(t, "owner", mut)
+
+		log := mut.StartRecording()
+		assert.Same(t, log, mut.StartRecording())
+
+		mut.Set("age", 6)
+		mut.Delete("breed")
+		mut.Delete("missing")
+		owner.Set("a/b", "changed")
+		tricks := mustGetSliceFromMap(t, "tricks", mut)
+		tricks.PushFront("beg")
+		tricks.Push("speak")
+		mustGetMapFromSlice(t, 3, tricks).Set("name", "play dead")
+		tricks.Set(0, map[string]any{"name": "stay"})
+		tricks.ReSlice(0, 4)
+
+		assert.Same(t, log, mut.StopRecording())
+		assert.Nil(t, mut.StopRecording())
+		mut.Set("unrecorded", true)
+		owner.Set("name", "Alex")
+
+		assert.Equal(t, []Mutation{
+			{Op: MutationSet, Path: "/age", Value: 6},
+			{Op: MutationDelete, Path: "/breed"},
+			{Op: MutationSet, Path: "/owner/a~1b", Value: "changed"},
+			{Op: MutationPushFront, Path: "/tricks", Value: "beg"},
+			{Op: MutationPush, Path: "/tricks", Value: "speak"},
+			{Op: MutationSet, Path: "/tricks/3/name", Value: "play dead"},
+			{Op: MutationSet, Path: "/tricks/0", Value: NewImmutableMap(map[string]any{"name": "stay"})},
+			{Op: MutationReSlice, Path: "/tricks", Left: 0, Right: 4},
+		}, log.Mutations())
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		mut := im.Mutable()
+		log := mut.StartRecording()
+
+		require.NoError(t, mut.SetPath("/owner/name", "Alex"))
+		require.NoError(t, mut.DeletePath("/tricks/0"))
+		require.NoError(t, mut.MergePatch(map[string]any{"owner": map[string]any{"a/b": nil}, "age": 6}))
+		require.NoError(t, mut.ApplyPatch(Patch{
+			{Op: PatchAdd, Path: "/tricks/1", Value: "beg"},
+			{Op: PatchMove, From: "/tricks/0", Path: "/first"},
+		}))
+		mustGetSliceFromMap(t, "tricks", mut).PushFront("speak")
+		require.NoError(t, mustGetMapFromMap(t, "owner", mut).UnmarshalJSON([]byte(`{"name":"Kim"}`)))
+		mut.StopRecording()
+
+		target := im.Mutable()
+		require.NoError(t, log.Replay(target))
+		assert.Equal(t, mut.Export(), target.Export())
+		assert.Equal(t, newSource(), im.Export())
+
+		// a replay which fails leaves the target unchanged
+		other := NewImmutableMap(map[string]any{"owner": "nobody"}).Mutable()
+		err := log.Replay(other)
+		assert.ErrorIs(t, err, ErrWrongType)
+		assert.Equal(t, map[string]any{"owner": "nobody"}, other.Export())
+
+		assert.ErrorIs(t, log.Replay(nil), ErrWrongType)
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		is := NewImmutableSlice([]any{[]any{1, 2}, "b"})
+		mut := is.Mutable()
+		log := mut.StartRecording()
+
+		mustGetSliceFromSlice(t, 0, mut).Push(3)
+		mut.PushFront("a")
+		mustGetSliceFromSlice(t, 1, mut).Set(0, 0)
+		mut.StopRecording()
+
+		assert.Equal(t, []Mutation{
+			{Op: MutationPush, Path: "/0", Value: 3},
+			{Op: MutationPushFront, Path: "", Value: "a"},
+			{Op: MutationSet, Path: "/1/0", Value: 0},
+		}, log.Mutations())
+
+		target := is.Mutable()
+		require.NoError(t, log.Replay(target))
+		assert.Equal(t, []any{"a", []any{0, 2, 3}, "b"}, target.Export())
+	})
+
+	t.Run("replacing a child stops recording its writes", func(t *testing.T) {
+		mut := NewImmutableMap(newSource()).Mutable()
+		log := mut.StartRecording()
+		owner := mustGetMapFromMap(t, "owner", mut)
+		mut.Set("owner", "nobody")
+		owner.Set("name", "Alex")
+		assert.Equal(t, 1, log.Len())
+	})
+}
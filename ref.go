@@ -0,0 +1,89 @@
+package green
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Ref is an atomic reference to an ImmutableMap, for publishing successive
+// snapshots of a value to concurrent readers. The zero value is a Ref holding
+// nil, ready to use.
+//
+// Ref methods are safe for concurrent use.
+type Ref struct {
+	p atomic.Pointer[ImmutableMap]
+	// mu serializes writes, so that watchers observe them in order.
+	mu sync.Mutex
+	// watchersMu guards watchers, which is replaced rather than modified in
+	// place.
+	watchersMu sync.Mutex
+	watchers   []*watcher
+}
+
+// NewRef returns a Ref holding the given ImmutableMap.
+func NewRef(m *ImmutableMap) *Ref {
+	r := &Ref{}
+	r.p.Store(m)
+	return r
+}
+
+// Load returns the ImmutableMap currently held by the Ref.
+//
+// This has O(1) time complexity.
+func (r *Ref) Load() *ImmutableMap {
+	return r.p.Load()
+}
+
+// Store replaces the ImmutableMap held by the Ref.
+//
+// This has O(1) time complexity, plus the cost of notifying watchers. See
+// Watch.
+func (r *Ref) Store(m *ImmutableMap) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.p.Swap(m)
+	r.notify(old, m)
+}
+
+// CompareAndSwap replaces the ImmutableMap held by the Ref with new if, and
+// only if, the Ref currently holds old. Maps are compared by pointer, not by
+// value. It reports whether the swap took place.
+//
+// This has O(1) time complexity, plus the cost of notifying watchers. See
+// Watch.
+func (r *Ref) CompareAndSwap(old, new *ImmutableMap) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.p.CompareAndSwap(old, new) {
+		return false
+	}
+	r.notify(old, new)
+	return true
+}
+
+// Update derives a Map from the ImmutableMap currently held by the Ref, calls f
+// to mutate it, and publishes the result of Immutable. If another writer
+// published a new value in the meantime, Update starts over from that value,
+// so no concurrent updates are lost. Consequently, f may be called more than
+// once and should not have side effects beyond mutating its argument. If the
+// Ref holds nil, f receives an empty Map. The published ImmutableMap is
+// returned.
+//
+// Each attempt has the time complexity of f plus that of Map.Immutable.
+func (r *Ref) Update(f func(*Map)) *ImmutableMap {
+	for {
+		old := r.p.Load()
+		base := old
+		if base == nil {
+			base = NewImmutableMap(nil)
+		}
+		m := base.Mutable()
+		f(m)
+		new := m.Immutable()
+		if r.CompareAndSwap(old, new) {
+			return new
+		}
+	}
+}
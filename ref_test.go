@@ -0,0 +1,73 @@
+package green
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRef(t *testing.T) {
+	t.Run("Load, Store, CompareAndSwap", func(t *testing.T) {
+		var zero Ref
+		assert.Nil(t, zero.Load())
+
+		im1 := NewImmutableMap(map[string]any{"a": 1})
+		im2 := NewImmutableMap(map[string]any{"a": 2})
+		r := NewRef(im1)
+		assert.Same(t, im1, r.Load())
+
+		assert.False(t, r.CompareAndSwap(im2, im2))
+		assert.Same(t, im1, r.Load())
+		assert.True(t, r.CompareAndSwap(im1, im2))
+		assert.Same(t, im2, r.Load())
+
+		r.Store(nil)
+		assert.Nil(t, r.Load())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		var r Ref
+		im := r.Update(func(m *Map) {
+			m.Set("count", 1)
+		})
+		assert.Same(t, im, r.Load())
+		assert.Equal(t, map[string]any{"count": 1}, im.Export())
+
+		im2 := r.Update(func(m *Map) {
+			n, err := m.GetInt64("count")
+			require.NoError(t, err)
+			m.Set("count", n+1)
+		})
+		assert.Equal(t, map[string]any{"count": int64(2)}, im2.Export())
+		assert.Equal(t, map[string]any{"count": 1}, im.Export())
+	})
+
+	t.Run("concurrent Update loses nothing", func(t *testing.T) {
+		r := NewRef(NewImmutableMap(map[string]any{"count": 0}))
+		const writers, updates = 8, 100
+
+		var wg sync.WaitGroup
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range updates {
+					r.Update(func(m *Map) {
+						n, _ := m.GetInt64("count")
+						m.Set("count", n+1)
+						m.Set(fmt.Sprintf("%d-%d", w, i), true)
+					})
+				}
+			}()
+		}
+		wg.Wait()
+
+		count, err := r.Load().GetInt64("count")
+		require.NoError(t, err)
+		assert.Equal(t, int64(writers*updates), count)
+		assert.Equal(t, writers*updates+1, r.Load().Len())
+	})
+}
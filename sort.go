@@ -0,0 +1,392 @@
+package green
+
+import (
+	"cmp"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Compare is a green-container-aware ordering of two values, for use in the
+// comparison functions passed to SortFunc, Sorted, and BinarySearchFunc. It
+// returns -1 if a is less than b, +1 if a is greater than b, and 0 otherwise.
+//
+// Values are ordered first by kind: nil, then booleans, numbers, strings,
+// slices, and maps, then values of any other type. Within a kind, false sorts
+// before true, numbers of any Go type are compared by their float64 value,
+// strings are compared lexically, slices are compared element by element, and
+// maps are compared by their sorted keys, then by the values of those keys.
+// Values of other types compare equal to each other. Containers may be
+// immutable, mutable, or native Go, so comparing by nested fields doesn't
+// require Export.
+//
+// This has O(n) time complexity in the worst case, where n is the number of
+// nodes in the graphs of the values, plus O(k*log(k)) for each pair of maps
+// compared, where k is the number of keys in the maps.
+func Compare(a, b any) int {
+	aRank, bRank := compareRank(a), compareRank(b)
+	if aRank != bRank {
+		return cmp.Compare(aRank, bRank)
+	}
+
+	switch aRank {
+	case rankBool:
+		aBool, bBool := a.(bool), b.(bool)
+		switch {
+		case aBool == bBool:
+			return 0
+		case aBool:
+			return 1
+		default:
+			return -1
+		}
+	case rankNumber:
+		aNum, _ := numberAsFloat64(a)
+		bNum, _ := numberAsFloat64(b)
+		return cmp.Compare(aNum, bNum)
+	case rankString:
+		return strings.Compare(a.(string), b.(string))
+	case rankSlice:
+		aValues, _ := sliceValues(a)
+		bValues, _ := sliceValues(b)
+		return compareSlices(aValues, bValues)
+	case rankMap:
+		aKeys, aGet, _ := mapValues(a)
+		bKeys, bGet, _ := mapValues(b)
+		if c := slices.Compare(aKeys, bKeys); c != 0 {
+			return c
+		}
+		for _, k := range aKeys {
+			if c := Compare(aGet(k), bGet(k)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+const (
+	rankNil = iota
+	rankBool
+	rankNumber
+	rankString
+	rankSlice
+	rankMap
+	rankOther
+)
+
+func compareRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return rankNil
+	case bool:
+		return rankBool
+	case string:
+		return rankString
+	}
+	if _, ok := numberAsFloat64(v); ok {
+		return rankNumber
+	}
+	if _, ok := sliceValues(v); ok {
+		return rankSlice
+	}
+	if _, _, ok := mapValues(v); ok {
+		return rankMap
+	}
+	return rankOther
+}
+
+func compareSlices(a, b iter.Seq[any]) int {
+	nextB, stop := iter.Pull(b)
+	defer stop()
+	for aValue := range a {
+		bValue, ok := nextB()
+		if !ok {
+			return 1
+		}
+		if c := Compare(aValue, bValue); c != 0 {
+			return c
+		}
+	}
+	if _, ok := nextB(); ok {
+		return -1
+	}
+	return 0
+}
+
+// sliceValues returns an iterator over the elements of v, if it is a slice of
+// any kind.
+func sliceValues(v any) (iter.Seq[any], bool) {
+	switch v := v.(type) {
+	case *ImmutableSlice:
+		return func(yield func(any) bool) {
+			for _, e := range v.All() {
+				if !yield(e) {
+					return
+				}
+			}
+		}, true
+	case *Slice:
+		return func(yield func(any) bool) {
+			for _, e := range v.All() {
+				if !yield(e) {
+					return
+				}
+			}
+		}, true
+	case []any:
+		return slices.Values(v), true
+	case sliceReader:
+		return func(yield func(any) bool) {
+			for i := range v.Len() {
+				if !yield(v.atAny(i)) {
+					return
+				}
+			}
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// mapValues returns the sorted keys of v, and a function to look up their
+// values, if it is a map of any kind.
+func mapValues(v any) ([]string, func(string) any, bool) {
+	var (
+		keys iter.Seq[string]
+		get  func(string) any
+	)
+	switch v := v.(type) {
+	case *ImmutableMap:
+		keys = func(yield func(string) bool) {
+			for k := range v.All() {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+		get = func(k string) any {
+			e, _ := v.Get(k)
+			return e
+		}
+	case *Map:
+		keys = func(yield func(string) bool) {
+			for k := range v.All() {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+		get = func(k string) any {
+			e, _ := v.Get(k)
+			return e
+		}
+	case map[string]any:
+		keys = maps.Keys(v)
+		get = func(k string) any {
+			return v[k]
+		}
+	case mapReader:
+		keys = func(yield func(string) bool) {
+			for k := range v.allAny() {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+		get = func(k string) any {
+			e, _ := v.getAny(k)
+			return e
+		}
+	default:
+		return nil, nil, false
+	}
+	return slices.Sorted(keys), get, true
+}
+
+// SortFunc sorts the Slice in ascending order as determined by the cmp
+// function, like slices.SortFunc. The values passed to cmp are those At would
+// return, so nested containers can be inspected without Export; see also
+// Compare. The sort is not guaranteed to be stable. If the Slice is nil, this
+// panics.
+//
+// Nested containers keep their identity, so existing references to them are
+// still shared with the Slice.
+//
+// This has O(n*log(n)) time complexity, where n is the number of elements in
+// the Slice.
+func (s *Slice) SortFunc(cmp func(a, b Value) int) {
+	if s == nil {
+		panic("*green.Slice.SortFunc: sort nil slice")
+	}
+
+	values := slices.Collect(s.values())
+	slices.SortFunc(values, cmp)
+	s.replaceAll(values)
+}
+
+// SortStableFunc is like SortFunc, but keeps the original order of equal
+// elements, like slices.SortStableFunc.
+//
+// This has O(n*log(n)) time complexity, where n is the number of elements in
+// the Slice.
+func (s *Slice) SortStableFunc(cmp func(a, b Value) int) {
+	if s == nil {
+		panic("*green.Slice.SortStableFunc: sort nil slice")
+	}
+
+	values := slices.Collect(s.values())
+	slices.SortStableFunc(values, cmp)
+	s.replaceAll(values)
+}
+
+// Reverse reverses the order of the elements in the Slice. If the Slice is
+// nil, this panics.
+//
+// This has O(n) time complexity, where n is the number of elements in the
+// Slice.
+func (s *Slice) Reverse() {
+	if s == nil {
+		panic("*green.Slice.Reverse: reverse nil slice")
+	}
+
+	values := slices.Collect(s.values())
+	slices.Reverse(values)
+	s.replaceAll(values)
+}
+
+// IndexFunc returns the first index i satisfying f(s.At(i)), or -1 if none do.
+// If the Slice is nil, this returns -1.
+//
+// This has O(k) time complexity, where k is the number of elements checked.
+func (s *Slice) IndexFunc(f func(Value) bool) int {
+	for i, v := range s.All() {
+		if f(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains reports whether the Slice has an element equal to v, as determined
+// by Equal. If the Slice is nil, this returns false.
+//
+// This has O(n) time complexity in the worst case, where n is the total number
+// of nodes in the graph representing the underlying value.
+func (s *Slice) Contains(v any) bool {
+	return s.IndexFunc(func(e Value) bool { return Equal(e, v) }) >= 0
+}
+
+// BinarySearchFunc searches for target in the Slice, which must be sorted in
+// ascending order as determined by the cmp function, like
+// slices.BinarySearchFunc. It returns the index where target is found, or
+// where it would be inserted, and whether it was found. The cmp function is
+// called with an element of the Slice and target.
+//
+// This has O(log(n)) time complexity, where n is the number of elements in the
+// Slice.
+func (s *Slice) BinarySearchFunc(target any, cmp func(e Value, target any) int) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	return searchFunc(s.Len(), s.At, target, cmp)
+}
+
+// Sorted returns a new ImmutableSlice holding the elements of the
+// ImmutableSlice in ascending order as determined by the cmp function. The
+// values passed to cmp are those At would return; see also Compare. Equal
+// elements keep their original order. The returned ImmutableSlice is backed by
+// a vector if the original is (see WithVector). If the ImmutableSlice is nil,
+// this returns nil.
+//
+// This has O(n*log(n)) time complexity, where n is the number of elements in
+// the slice.
+func (s *ImmutableSlice) Sorted(cmp func(a, b ImmutableValue) int) *ImmutableSlice {
+	if s == nil {
+		return nil
+	}
+
+	values := make([]ImmutableValue, 0, s.Len())
+	for _, v := range s.All() {
+		values = append(values, v)
+	}
+	slices.SortStableFunc(values, cmp)
+
+	base := make([]any, len(values))
+	for i, v := range values {
+		base[i] = v
+	}
+	if s.vec != nil {
+		return &ImmutableSlice{vec: newVector(base, asImmutable)}
+	}
+	return &ImmutableSlice{base: base}
+}
+
+// IndexFunc is like Slice.IndexFunc, but for an ImmutableSlice.
+//
+// This has O(k) time complexity, where k is the number of elements checked.
+func (s *ImmutableSlice) IndexFunc(f func(ImmutableValue) bool) int {
+	for i, v := range s.All() {
+		if f(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains is like Slice.Contains, but for an ImmutableSlice.
+//
+// This has O(n) time complexity in the worst case, where n is the total number
+// of nodes in the graph representing the underlying value.
+func (s *ImmutableSlice) Contains(v any) bool {
+	return s.IndexFunc(func(e ImmutableValue) bool { return Equal(e, v) }) >= 0
+}
+
+// BinarySearchFunc is like Slice.BinarySearchFunc, but for an ImmutableSlice.
+//
+// This has O(log(n)) time complexity, where n is the number of elements in the
+// slice.
+func (s *ImmutableSlice) BinarySearchFunc(target any, cmp func(e ImmutableValue, target any) int) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	return searchFunc(s.Len(), s.At, target, cmp)
+}
+
+// searchFunc implements BinarySearchFunc over n elements returned by at.
+func searchFunc[E any](n int, at func(int) E, target any, cmp func(E, any) int) (int, bool) {
+	i, j := 0, n
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if cmp(at(h), target) < 0 {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i, i < n && cmp(at(i), target) == 0
+}
+
+// values returns an iterator over the values All would yield.
+func (s *Slice) values() iter.Seq[Value] {
+	return func(yield func(Value) bool) {
+		for _, v := range s.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// replaceAll replaces the contents of the Slice with the values, which are
+// its own elements in a new order.
+func (s *Slice) replaceAll(values []Value) {
+	vals := make([]any, len(values))
+	for i, v := range values {
+		vals[i] = v
+	}
+	s.splice(0, s.Len(), vals)
+}
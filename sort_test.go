@@ -0,0 +1,159 @@
+package green
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSort(t *testing.T) {
+	byName := func(a, b Value) int {
+		aName, _ := a.(*Map).GetString("name")
+		bName, _ := b.(*Map).GetString("name")
+		return Compare(aName, bName)
+	}
+	newDogs := func() []any {
+		return []any{
+			map[string]any{"name": "Rex", "age": 3},
+			map[string]any{"name": "Ace", "age": 6},
+			map[string]any{"name": "Max", "age": 3},
+		}
+	}
+
+	t.Run("Compare", func(t *testing.T) {
+		ordered := []any{
+			nil,
+			false,
+			true,
+			-1.5,
+			int64(2),
+			json.Number("3"),
+			"",
+			"a",
+			[]any{},
+			NewImmutableSlice([]any{1}),
+			[]any{1, 2},
+			NewImmutableSliceOf([]int{1, 3}),
+			map[string]any{},
+			NewImmutableMap(map[string]any{"a": 1}).Mutable(),
+			map[string]any{"a": 2},
+			NewImmutableMapOf(map[string]int{"b": 0}),
+			struct{}{},
+		}
+		for i, a := range ordered {
+			for j, b := range ordered {
+				switch {
+				case i < j:
+					assert.Equal(t, -1, Compare(a, b), "%v < %v", a, b)
+				case i > j:
+					assert.Equal(t, 1, Compare(a, b), "%v > %v", a, b)
+				default:
+					assert.Equal(t, 0, Compare(a, b), "%v == %v", a, b)
+				}
+			}
+		}
+		assert.Equal(t, 0, Compare(1, 1.0))
+		assert.Equal(t, 0, Compare(NewImmutableSlice([]any{1, "a"}), []any{1.0, "a"}))
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{"dogs": newDogs()})
+		mut := im.Mutable()
+		dogs := mustGetSliceFromMap(t, "dogs", mut)
+		rex := mustGetMapFromSlice(t, 0, dogs)
+
+		dogs.SortFunc(byName)
+		assert.True(t, mut.dirty)
+		assert.Same(t, rex, dogs.At(2))
+		rex.Set("age", 4)
+		assert.Equal(t, []any{
+			map[string]any{"name": "Ace", "age": 6},
+			map[string]any{"name": "Max", "age": 3},
+			map[string]any{"name": "Rex", "age": 4},
+		}, mut.Immutable().Export()["dogs"])
+		assert.Equal(t, map[string]any{"dogs": newDogs()}, im.Export())
+
+		i, found := dogs.BinarySearchFunc("Max", func(e Value, target any) int {
+			name, _ := e.(*Map).GetString("name")
+			return Compare(name, target)
+		})
+		assert.Equal(t, 1, i)
+		assert.True(t, found)
+		i, found = dogs.BinarySearchFunc("Bo", func(e Value, target any) int {
+			name, _ := e.(*Map).GetString("name")
+			return Compare(name, target)
+		})
+		assert.Equal(t, 1, i)
+		assert.False(t, found)
+
+		dogs.SortStableFunc(func(a, b Value) int {
+			aAge, _ := a.(*Map).GetInt64("age")
+			bAge, _ := b.(*Map).GetInt64("age")
+			return Compare(aAge, bAge)
+		})
+		dogs.Reverse()
+		assert.Equal(t, []any{
+			map[string]any{"name": "Ace", "age": 6},
+			map[string]any{"name": "Rex", "age": 4},
+			map[string]any{"name": "Max", "age": 3},
+		}, dogs.Export())
+
+		assert.Equal(t, 1, dogs.IndexFunc(func(v Value) bool { return v == rex }))
+		assert.True(t, dogs.Contains(map[string]any{"name": "Max", "age": 3}))
+		assert.False(t, dogs.Contains(map[string]any{"name": "Max"}))
+
+		var nilSlice *Slice
+		assert.Equal(t, -1, nilSlice.IndexFunc(func(Value) bool { return true }))
+		assert.Panics(t, func() { nilSlice.Reverse() })
+	})
+
+	t.Run("recording", func(t *testing.T) {
+		is := NewImmutableSlice([]any{3, 1, 2})
+		mut := is.Mutable()
+		log := mut.StartRecording()
+		mut.SortFunc(func(a, b Value) int { return Compare(a, b) })
+		mut.Reverse()
+		mut.StopRecording()
+		assert.Equal(t, []any{3, 2, 1}, mut.Export())
+
+		target := is.Mutable()
+		require.NoError(t, log.Replay(target))
+		assert.Equal(t, []any{3, 2, 1}, target.Export())
+	})
+
+	t.Run("ImmutableSlice", func(t *testing.T) {
+		is := NewImmutableSlice(newDogs())
+		ace := is.At(1)
+		sorted := is.Sorted(func(a, b ImmutableValue) int {
+			aAge, _ := a.(*ImmutableMap).GetInt64("age")
+			bAge, _ := b.(*ImmutableMap).GetInt64("age")
+			return Compare(aAge, bAge)
+		})
+		assert.Equal(t, []any{
+			map[string]any{"name": "Rex", "age": 3},
+			map[string]any{"name": "Max", "age": 3},
+			map[string]any{"name": "Ace", "age": 6},
+		}, sorted.Export())
+		assert.Same(t, ace, sorted.At(2))
+		assert.Equal(t, newDogs(), is.Export())
+
+		assert.Equal(t, 2, sorted.IndexFunc(func(v ImmutableValue) bool { return v == ace }))
+		assert.True(t, sorted.Contains(map[string]any{"name": "Ace", "age": 6}))
+		assert.False(t, sorted.Contains("Ace"))
+
+		scalars := NewImmutableSlice([]any{"b", 1, nil, "a", true}, WithVector()).Sorted(func(a, b ImmutableValue) int { return Compare(a, b) })
+		require.NotNil(t, scalars.vec)
+		assert.Equal(t, []any{nil, true, 1, "a", "b"}, scalars.Export())
+		i, found := scalars.BinarySearchFunc("a", func(e ImmutableValue, target any) int { return Compare(e, target) })
+		assert.Equal(t, 3, i)
+		assert.True(t, found)
+
+		var nilSlice *ImmutableSlice
+		assert.Nil(t, nilSlice.Sorted(func(a, b ImmutableValue) int { return 0 }))
+		i, found = nilSlice.BinarySearchFunc("a", func(e ImmutableValue, target any) int { return 0 })
+		assert.Equal(t, 0, i)
+		assert.False(t, found)
+	})
+}
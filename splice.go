@@ -0,0 +1,175 @@
+package green
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Insert inserts the given values at the specified index in the Slice,
+// shifting the elements at and after the index to the right. Like
+// slices.Insert, if the index is out of the range [0, s.Len()], this function
+// panics.
+//
+// Values passed into the Insert function should not be mutated after being
+// inserted. Normal Go values can be passed in, along with immutable and
+// mutable containers.
+//
+// This has O(min(i, n-i)+v) average time complexity, where n is the number of
+// elements in the Slice and v is the number of values inserted. See Splice.
+func (s *Slice) Insert(index int, vals ...any) {
+	if index < 0 || index > s.Len() {
+		panic(fmt.Sprintf("*green.Slice.Insert: index out of range [%d] with length %d", index, s.Len()))
+	}
+
+	s.splice(index, index, vals)
+}
+
+// Remove removes the element at the specified index in the Slice, shifting the
+// elements after it to the left. Like a native Go slice, if the index is out
+// of bounds, this function panics.
+//
+// This has O(min(i, n-i)) average time complexity, where n is the number of
+// elements in the Slice. See Splice.
+func (s *Slice) Remove(index int) {
+	if index < 0 || index >= s.Len() {
+		panic(fmt.Sprintf("*green.Slice.Remove: index out of range [%d] with length %d", index, s.Len()))
+	}
+
+	s.splice(index, index+1, nil)
+}
+
+// RemoveRange removes the elements from the given left index (inclusive) to
+// the right index (exclusive) from the Slice, shifting the elements after them
+// to the left. Like a native Go slice, if the indexes are out of bounds, or if
+// left > right, this function panics.
+//
+// This has O(min(l, n-r)) average time complexity, where n is the number of
+// elements in the Slice. See Splice.
+func (s *Slice) RemoveRange(left, right int) {
+	s.checkRange(left, right, "RemoveRange")
+	s.splice(left, right, nil)
+}
+
+// Splice replaces the elements from the given left index (inclusive) to the
+// right index (exclusive) in the Slice with the given values, like
+// slices.Replace. Like a native Go slice, if the indexes are out of bounds, or
+// if left > right, this function panics.
+//
+// The elements on the shorter side of the replaced range are moved into the
+// prepends or appends of the Slice, so no elements are copied out of the
+// ImmutableSlice the Slice was derived from, and Immutable costs no more than
+// it would after the equivalent calls to ReSlice, Push, and PushFront.
+//
+// Values passed into the Splice function should not be mutated after being
+// inserted. Normal Go values can be passed in, along with immutable and
+// mutable containers.
+//
+// This has O(min(l, n-r)+v) average time complexity, where n is the number of
+// elements in the Slice and v is the number of values inserted.
+func (s *Slice) Splice(left, right int, vals ...any) {
+	s.checkRange(left, right, "Splice")
+	s.splice(left, right, vals)
+}
+
+// Pop removes and returns the last element of the Slice. If the Slice is nil
+// or empty, this panics.
+//
+// This has O(1) average time complexity.
+func (s *Slice) Pop() Value {
+	if s == nil || s.Len() == 0 {
+		panic("*green.Slice.Pop: pop from empty slice")
+	}
+
+	v := s.At(s.Len() - 1)
+	s.splice(s.Len()-1, s.Len(), nil)
+	return v
+}
+
+// PopFront removes and returns the first element of the Slice. If the Slice is
+// nil or empty, this panics.
+//
+// This has O(1) average time complexity.
+func (s *Slice) PopFront() Value {
+	if s == nil || s.Len() == 0 {
+		panic("*green.Slice.PopFront: pop from empty slice")
+	}
+
+	v := s.At(0)
+	s.splice(0, 1, nil)
+	return v
+}
+
+func (s *Slice) checkRange(left, right int, funcName string) {
+	if left < 0 {
+		panic(fmt.Sprintf("*green.Slice.%s: index out of range [%d]", funcName, left))
+	}
+	if right > s.Len() {
+		panic(fmt.Sprintf("*green.Slice.%s: index out of range [%d] with length %d", funcName, right, s.Len()))
+	}
+	if left > right {
+		panic(fmt.Sprintf("*green.Slice.%s: slice bounds out of range [%d:%d]", funcName, left, right))
+	}
+}
+
+// splice implements Splice for bounds which have been checked.
+func (s *Slice) splice(left, right int, vals []any) {
+	if left == right && len(vals) == 0 {
+		return
+	}
+
+	n := s.Len()
+	if left <= n-right {
+		// move the head into the prepends, in front of the values
+		head := make([]any, left)
+		for i := range left {
+			head[i] = s.rawAt(i)
+		}
+		s.reslice(right, n, "Splice")
+		// the prepends may share an underlying array with SubSlices
+		s.prepends = slices.Clip(s.prepends)
+		for _, v := range slices.Backward(vals) {
+			s.prepends = append(s.prepends, v)
+		}
+		for _, v := range slices.Backward(head) {
+			s.prepends = append(s.prepends, v)
+		}
+	} else {
+		// move the tail into the appends, behind the values
+		tail := make([]any, n-right)
+		for i := range tail {
+			tail[i] = s.rawAt(right + i)
+		}
+		s.reslice(0, left, "Splice")
+		// the appends may share an underlying array with SubSlices
+		s.appends = slices.Clip(s.appends)
+		s.appends = append(s.appends, vals...)
+		s.appends = append(s.appends, tail...)
+	}
+
+	s.reportDirty()
+	if s.recording > 0 {
+		inserted := make([]any, len(vals))
+		for i, v := range vals {
+			inserted[i] = asImmutable(v)
+		}
+		s.record(Mutation{Op: MutationSplice, Value: NewImmutableSlice(inserted), Left: left, Right: right}, nil)
+	}
+}
+
+// rawAt is like At, but returns the value without wrapping it in a mutable
+// container.
+func (s *Slice) rawAt(index int) any {
+	if index < len(s.prepends) {
+		return s.prepends[s.prependIndex(index)]
+	}
+	index -= len(s.prepends)
+
+	if index < s.base.Len() {
+		if v, ok := s.getOverride(index); ok {
+			return v
+		}
+		return s.base.At(index)
+	}
+
+	return s.appends[index-s.base.Len()]
+}
@@ -0,0 +1,144 @@
+package green
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplice(t *testing.T) {
+	t.Run("matches slices.Replace", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(5, 6))
+		want := make([]any, 0, 100)
+		for i := range 100 {
+			want = append(want, i)
+		}
+		is := NewImmutableSlice(slices.Clone(want))
+		mut := is.Mutable()
+		next := 100
+		for range 500 {
+			l := rng.IntN(len(want) + 1)
+			r := l + rng.IntN(min(len(want)-l, 5)+1)
+			vals := make([]any, rng.IntN(4))
+			for i := range vals {
+				vals[i] = next
+				next++
+			}
+			switch rng.IntN(4) {
+			case 0:
+				mut.Splice(l, r, vals...)
+				want = slices.Replace(want, l, r, vals...)
+			case 1:
+				mut.Insert(l, vals...)
+				want = slices.Insert(want, l, vals...)
+			case 2:
+				mut.RemoveRange(l, r)
+				want = slices.Delete(want, l, r)
+			default:
+				if rng.IntN(2) == 0 {
+					mut.Push(next)
+				} else {
+					mut.PushFront(next)
+					want = slices.Insert(want, 0, any(next))
+					next++
+					continue
+				}
+				want = append(want, next)
+				next++
+			}
+			require.Equal(t, want, mut.Export())
+		}
+		assert.Equal(t, want, mut.Immutable().Export())
+		assert.Len(t, is.Export(), 100)
+	})
+
+	t.Run("Insert, Remove, Pop and PopFront", func(t *testing.T) {
+		im := NewImmutableMap(map[string]any{
+			"tricks": []any{"sit", map[string]any{"name": "roll"}, "shake"},
+		})
+		mut := im.Mutable()
+		tricks := mustGetSliceFromMap(t, "tricks", mut)
+		roll := mustGetMapFromSlice(t, 1, tricks)
+
+		tricks.Insert(1, "beg", "stay")
+		tricks.Remove(0)
+		assert.True(t, mut.dirty, "parents are marked dirty")
+		assert.Equal(t, []any{"beg", "stay", map[string]any{"name": "roll"}, "shake"}, tricks.Export())
+
+		// moved containers keep their identity
+		assert.Same(t, roll, tricks.At(2))
+		roll.Set("name", "play dead")
+
+		assert.Equal(t, "shake", tricks.Pop())
+		assert.Equal(t, "beg", tricks.PopFront())
+		assert.Equal(t, map[string]any{
+			"tricks": []any{"stay", map[string]any{"name": "play dead"}},
+		}, mut.Immutable().Export())
+		assert.Equal(t, map[string]any{
+			"tricks": []any{"sit", map[string]any{"name": "roll"}, "shake"},
+		}, im.Export())
+
+		assert.Same(t, roll, tricks.Pop())
+		tricks.PopFront()
+		assert.Equal(t, 0, tricks.Len())
+		assert.PanicsWithValue(t, "*green.Slice.Pop: pop from empty slice", func() { tricks.Pop() })
+		assert.PanicsWithValue(t, "*green.Slice.PopFront: pop from empty slice", func() { tricks.PopFront() })
+		assert.PanicsWithValue(t, "*green.Slice.Insert: index out of range [1] with length 0", func() { tricks.Insert(1, "x") })
+		assert.PanicsWithValue(t, "*green.Slice.Remove: index out of range [0] with length 0", func() { tricks.Remove(0) })
+		assert.PanicsWithValue(t, "*green.Slice.RemoveRange: index out of range [1] with length 0", func() { tricks.RemoveRange(0, 1) })
+		assert.PanicsWithValue(t, "*green.Slice.Splice: index out of range [-1]", func() { tricks.Splice(-1, 0) })
+	})
+
+	t.Run("SubSlices are unaffected", func(t *testing.T) {
+		mut := NewImmutableSlice([]any{1, 2, 3}).Mutable()
+		mut.Push(4)
+		mut.PushFront(0)
+		sub := mut.SubSlice(0, 4)
+		mut.Insert(4, "x")
+		mut.Insert(1, "y")
+		assert.Equal(t, []any{0, "y", 1, 2, 3, "x", 4}, mut.Export())
+		assert.Equal(t, []any{0, 1, 2, 3}, sub.Export())
+	})
+
+	t.Run("vector-backed slices stay vectors", func(t *testing.T) {
+		src := make([]any, 1000)
+		for i := range src {
+			src[i] = i
+		}
+		mut := NewImmutableSlice(src, WithVector()).Mutable()
+		mut.Remove(2)
+		mut.Insert(990, "x")
+		is := mut.Immutable()
+		require.NotNil(t, is.vec)
+		assert.Equal(t, 3, is.At(2))
+		assert.Equal(t, "x", is.At(990))
+		assert.Equal(t, 991, is.At(991))
+		assert.Equal(t, 1000, is.Len())
+	})
+
+	t.Run("recording and replay", func(t *testing.T) {
+		is := NewImmutableSlice([]any{"a", "b", "c", "d"})
+		mut := is.Mutable()
+		log := mut.StartRecording()
+		mut.Splice(1, 3, "x")
+		mut.Insert(0, []any{1})
+		mustGetSliceFromSlice(t, 0, mut).Pop()
+		mut.PopFront()
+		mut.StopRecording()
+
+		assert.Equal(t, []Mutation{
+			{Op: MutationSplice, Value: NewImmutableSlice([]any{"x"}), Left: 1, Right: 3},
+			{Op: MutationSplice, Value: NewImmutableSlice([]any{NewImmutableSlice([]any{1})}), Left: 0, Right: 0},
+			{Op: MutationSplice, Path: "/0", Value: NewImmutableSlice([]any{}), Left: 0, Right: 1},
+			{Op: MutationSplice, Value: NewImmutableSlice([]any{}), Left: 0, Right: 1},
+		}, log.Mutations())
+
+		target := is.Mutable()
+		require.NoError(t, log.Replay(target))
+		assert.Equal(t, []any{"a", "x", "d"}, target.Export())
+		assert.Equal(t, mut.Export(), target.Export())
+	})
+}
@@ -0,0 +1,870 @@
+package green
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+type (
+	// ImmutableMapOf is a generic counterpart to ImmutableMap for maps whose
+	// values all have type V.
+	//
+	// Unlike ImmutableMap, values are returned as is rather than being wrapped
+	// in immutable containers, so V should either be a type without reference
+	// semantics (e.g. string or int) or an immutable container type (e.g.
+	// *ImmutableMap); otherwise, callers must take care not to mutate values
+	// obtained from it.
+	//
+	// ImmutableMapOf methods are safe for concurrent use.
+	ImmutableMapOf[V any] struct {
+		inherited   *MapOf[V]
+		base        map[string]V
+		jsonBytes   []byte
+		jsonError   error
+		jsonMarshal sync.Once
+	}
+
+	// ImmutableSliceOf is a generic counterpart to ImmutableSlice for slices
+	// whose elements all have type T. See ImmutableMapOf for the caveats on
+	// the element type.
+	//
+	// ImmutableSliceOf methods are safe for concurrent use.
+	ImmutableSliceOf[T any] struct {
+		base        []T
+		jsonBytes   []byte
+		jsonError   error
+		jsonMarshal sync.Once
+	}
+
+	// MapOf is a generic counterpart to Map for maps whose values all have type
+	// V, with the same copy-on-write semantics.
+	//
+	// The methods for MapOf are NOT SAFE for concurrent use. To safely read
+	// from a MapOf concurrently, first convert it to an ImmutableMapOf via
+	// Immutable().
+	MapOf[V any] struct {
+		base *ImmutableMapOf[V]
+		// overwrites contains values which override values in base.
+		overwrites map[string]typedOverwrite[V]
+		// dirty tracks whether this map has been mutated since creation.
+		dirty bool
+		// len is tracked manually as the MapOf is mutated to provide O(1)
+		// Len() calls.
+		len int
+	}
+
+	// SliceOf is a generic counterpart to Slice for slices whose elements all
+	// have type T, with the same copy-on-write semantics.
+	//
+	// The methods for SliceOf are NOT SAFE for concurrent use. To safely read
+	// from a SliceOf concurrently, first convert it to an ImmutableSliceOf via
+	// Immutable().
+	SliceOf[T any] struct {
+		base *ImmutableSliceOf[T]
+		// overwrites are writes that overrides values in base.
+		overwrites map[int]T
+		// overwriteOffset is the offset to apply to overwrite keys to map them
+		// to the base slice's indexes.
+		overwriteOffset int
+		// appends are elements inserted at the end via Push.
+		appends []T
+		// prepends are elements inserted at the beginning via PushFront, in
+		// reverse order.
+		prepends []T
+		// dirty tracks whether this slice has been mutated since creation.
+		dirty bool
+	}
+
+	// typedOverwrite is a value written to a MapOf. Since V may not be able to
+	// hold a sentinel, deletions are flagged explicitly.
+	typedOverwrite[V any] struct {
+		val     V
+		deleted bool
+	}
+)
+
+// NewImmutableMapOf wraps a map and returns an ImmutableMapOf which grants
+// read-only access to it. The map should not be modified after being passed
+// into this function.
+//
+// This has O(1) time complexity.
+func NewImmutableMapOf[V any](m map[string]V) *ImmutableMapOf[V] {
+	return &ImmutableMapOf[V]{base: m}
+}
+
+// NewImmutableSliceOf wraps a slice and returns an ImmutableSliceOf which
+// grants read-only access to it. The slice should not be modified after being
+// passed into this function.
+//
+// This has O(1) time complexity.
+func NewImmutableSliceOf[T any](s []T) *ImmutableSliceOf[T] {
+	return &ImmutableSliceOf[T]{base: s}
+}
+
+// Get retrieves the value associated with the given key in the ImmutableMapOf
+// and a boolean indicating whether a value for that key exists. If the
+// ImmutableMapOf is nil, this always returns the zero value and false.
+//
+// This has O(1) average time complexity.
+func (m *ImmutableMapOf[V]) Get(key string) (V, bool) {
+	if m == nil {
+		var zero V
+		return zero, false
+	}
+
+	if m.inherited != nil {
+		return m.inherited.Get(key)
+	}
+
+	v, ok := m.base[key]
+	return v, ok
+}
+
+// Has returns whether the ImmutableMapOf contains a value for the given key.
+// If the ImmutableMapOf is nil, this always returns false.
+//
+// This has O(1) time complexity.
+func (m *ImmutableMapOf[V]) Has(key string) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Len returns the number of fields in the ImmutableMapOf. If the
+// ImmutableMapOf is nil, it returns 0.
+//
+// This has O(1) time complexity.
+func (m *ImmutableMapOf[V]) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	if m.inherited != nil {
+		return m.inherited.Len()
+	}
+
+	return len(m.base)
+}
+
+// All returns an iterator over all key, value pairs in the ImmutableMapOf. Like
+// iterating over a native Go map, the order of pairs is non-deterministic. This
+// function yields nothing if the ImmutableMapOf is nil.
+//
+// This has O(k') average time complexity, where k' is the number of key-value
+// pairs in the map which get iterated over.
+func (m *ImmutableMapOf[V]) All() iter.Seq2[string, V] {
+	if m != nil && m.inherited != nil {
+		return m.inherited.All()
+	}
+
+	return func(yield func(string, V) bool) {
+		if m == nil {
+			return
+		}
+
+		for k, v := range m.base {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Mutable derives a mutable version of the ImmutableMapOf. Subsequent
+// mutations to the returned MapOf do not affect the ImmutableMapOf. If the
+// ImmutableMapOf is nil, this returns nil.
+//
+// This has O(1) time complexity.
+func (m *ImmutableMapOf[V]) Mutable() *MapOf[V] {
+	if m == nil {
+		return nil
+	}
+
+	return &MapOf[V]{base: m, len: m.Len()}
+}
+
+// Export returns a copy of the map. Modifying this map does not affect the
+// ImmutableMapOf. Values are copied shallowly. If the ImmutableMapOf is nil,
+// this returns nil.
+//
+// This has O(k) time complexity, where k is the number of key-value pairs in
+// the map.
+func (m *ImmutableMapOf[V]) Export() map[string]V {
+	if m == nil {
+		return nil
+	}
+
+	if m.inherited == nil {
+		return maps.Clone(m.base)
+	}
+
+	return maps.Collect(m.All())
+}
+
+func (m *ImmutableMapOf[V]) Equal(other any) bool {
+	if b, ok := other.(*ImmutableMapOf[V]); ok && b == m {
+		return true
+	}
+	return equalMapReader(m, other)
+}
+
+func (m *ImmutableMapOf[V]) MarshalJSON() ([]byte, error) {
+	m.jsonMarshal.Do(func() {
+		m.jsonBytes, m.jsonError = json.Marshal(m.Export())
+	})
+	return m.jsonBytes, m.jsonError
+}
+
+func (m *ImmutableMapOf[V]) getAny(key string) (any, bool) {
+	v, ok := m.Get(key)
+	if !ok {
+		return nil, false
+	}
+	iv, _ := isContainer(v)
+	return iv, true
+}
+
+func (m *ImmutableMapOf[V]) allAny() iter.Seq2[string, any] {
+	return typedAllAny(m.All())
+}
+
+// At retrieves the element at the specified index. Like a native Go slice, if
+// the index is out of bounds, this function panics.
+//
+// This has O(1) time complexity.
+func (s *ImmutableSliceOf[T]) At(index int) T {
+	if index < 0 {
+		panic(fmt.Sprintf("*green.ImmutableSliceOf.At: index out of range [%d]", index))
+	}
+	if index >= s.Len() {
+		panic(fmt.Sprintf("*green.ImmutableSliceOf.At: index out of range [%d] with length %d", index, s.Len()))
+	}
+
+	return s.base[index]
+}
+
+// Len returns the number of elements in the ImmutableSliceOf. If the
+// ImmutableSliceOf is nil, it returns 0.
+//
+// This has O(1) time complexity.
+func (s *ImmutableSliceOf[T]) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	return len(s.base)
+}
+
+// SubSlice returns a new ImmutableSliceOf representing the subslice of the
+// original ImmutableSliceOf from the given left index (inclusive) to the right
+// index (exclusive). Like a native Go slice, if the indexes are out of bounds,
+// or if left > right, this function panics. The subslice shares memory with
+// the original.
+//
+// This has O(1) time complexity.
+func (s *ImmutableSliceOf[T]) SubSlice(left, right int) *ImmutableSliceOf[T] {
+	if left < 0 {
+		panic(fmt.Sprintf("*green.ImmutableSliceOf.SubSlice: index out of range [%d]", left))
+	}
+	if right > s.Len() {
+		panic(fmt.Sprintf("*green.ImmutableSliceOf.SubSlice: index out of range [%d] with length %d", right, s.Len()))
+	}
+	if left > right {
+		panic(fmt.Sprintf("*green.ImmutableSliceOf.SubSlice: slice bounds out of range [%d:%d]", left, right))
+	}
+
+	if left == 0 && right == s.Len() {
+		return s
+	}
+
+	return &ImmutableSliceOf[T]{base: s.base[left:right:right]}
+}
+
+// All returns an iterator over all elements in the ImmutableSliceOf in order.
+// This function yields nothing if the ImmutableSliceOf is nil.
+//
+// This has O(k') time complexity, where k' is the number of elements in the
+// slice which get iterated over.
+func (s *ImmutableSliceOf[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if s == nil {
+			return
+		}
+
+		for i, v := range s.base {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// Mutable derives a mutable version of the ImmutableSliceOf. Subsequent
+// mutations to the returned SliceOf do not affect the ImmutableSliceOf. If the
+// ImmutableSliceOf is nil, this returns nil.
+//
+// This has O(1) time complexity.
+func (s *ImmutableSliceOf[T]) Mutable() *SliceOf[T] {
+	if s == nil {
+		return nil
+	}
+
+	return &SliceOf[T]{base: s}
+}
+
+// Export returns a copy of the slice. Modifying this slice does not affect the
+// ImmutableSliceOf. Elements are copied shallowly. If the ImmutableSliceOf is
+// nil, this returns nil.
+//
+// This has O(k) time complexity, where k is the number of elements in the
+// slice.
+func (s *ImmutableSliceOf[T]) Export() []T {
+	if s == nil {
+		return nil
+	}
+
+	return slices.Clone(s.base)
+}
+
+func (s *ImmutableSliceOf[T]) Equal(other any) bool {
+	if b, ok := other.(*ImmutableSliceOf[T]); ok && b == s {
+		return true
+	}
+	return equalSliceReader(s, other)
+}
+
+func (s *ImmutableSliceOf[T]) MarshalJSON() ([]byte, error) {
+	s.jsonMarshal.Do(func() {
+		s.jsonBytes, s.jsonError = json.Marshal(s.base)
+	})
+	return s.jsonBytes, s.jsonError
+}
+
+func (s *ImmutableSliceOf[T]) atAny(index int) any {
+	iv, _ := isContainer(s.At(index))
+	return iv
+}
+
+// Get is like Map.Get, but values are returned as is.
+//
+// This has O(1) average time complexity.
+func (m *MapOf[V]) Get(key string) (V, bool) {
+	if m == nil {
+		var zero V
+		return zero, false
+	}
+
+	if o, ok := m.overwrites[key]; ok {
+		return o.val, !o.deleted
+	}
+
+	return m.base.Get(key)
+}
+
+// Has returns whether the MapOf contains a value for the given key. If the
+// MapOf is nil, this always returns false.
+//
+// This has O(1) time complexity.
+func (m *MapOf[V]) Has(key string) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Set sets the value for the given key in the MapOf. If the MapOf is nil, this
+// panics.
+//
+// This has O(1) average time complexity.
+func (m *MapOf[V]) Set(key string, val V) {
+	if m == nil {
+		panic("*green.MapOf: assignment to entry in nil map")
+	}
+
+	keyExisted := m.Has(key)
+	m.setOverwrite(key, typedOverwrite[V]{val: val})
+	if !keyExisted {
+		m.len++
+	}
+	m.dirty = true
+}
+
+// Delete removes the value for the given key in the MapOf. If the MapOf is
+// nil, this is a no-op.
+//
+// This has O(1) average time complexity.
+func (m *MapOf[V]) Delete(key string) {
+	if m == nil {
+		return
+	}
+
+	if m.Has(key) {
+		m.setOverwrite(key, typedOverwrite[V]{deleted: true})
+		m.len--
+		m.dirty = true
+	}
+}
+
+// Len returns the number of fields in the MapOf. If the MapOf is nil, it
+// returns 0.
+//
+// This has O(1) time complexity.
+func (m *MapOf[V]) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.len
+}
+
+// All returns an iterator over all key, value pairs in the MapOf. Like
+// iterating over a native Go map, the order of pairs is non-deterministic. This
+// function yields nothing if the MapOf is nil.
+//
+// This has O(k') average time complexity, where k' is the number of key-value
+// pairs in the MapOf which get iterated over.
+func (m *MapOf[V]) All() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		if m == nil {
+			return
+		}
+		for k, o := range m.overwrites {
+			if o.deleted {
+				continue
+			}
+			if !yield(k, o.val) {
+				return
+			}
+		}
+		for k, v := range m.base.All() {
+			if _, overwritten := m.overwrites[k]; overwritten {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Immutable returns an immutable version of the MapOf. Subsequent mutations to
+// the MapOf do not affect the returned ImmutableMapOf. If the MapOf is nil,
+// this returns nil.
+//
+// This has O(w) time complexity, where w is the number of distinct keys Set or
+// Deleted on the MapOf.
+func (m *MapOf[V]) Immutable() *ImmutableMapOf[V] {
+	if m == nil {
+		return nil
+	}
+	if !m.dirty {
+		return m.base
+	}
+
+	return &ImmutableMapOf[V]{
+		inherited: &MapOf[V]{
+			overwrites: maps.Clone(m.overwrites),
+			base:       m.base,
+			len:        m.len,
+		},
+	}
+}
+
+// Clone returns a copy of the MapOf. Subsequent mutations to the clone do not
+// affect the original MapOf, and vice versa. If the MapOf is nil, this returns
+// nil.
+//
+// This has O(w) time complexity, where w is the number of distinct keys Set or
+// Deleted on the MapOf.
+func (m *MapOf[V]) Clone() *MapOf[V] {
+	if m == nil {
+		return nil
+	}
+
+	return &MapOf[V]{
+		base:       m.base,
+		overwrites: maps.Clone(m.overwrites),
+		dirty:      m.dirty,
+		len:        m.len,
+	}
+}
+
+// Export returns a copy of the MapOf as a native Go map. Values are copied
+// shallowly. If the MapOf is nil, this returns nil.
+//
+// This has O(k) time complexity, where k is the number of key-value pairs in
+// the MapOf.
+func (m *MapOf[V]) Export() map[string]V {
+	if m == nil {
+		return nil
+	}
+	if !m.dirty {
+		return m.base.Export()
+	}
+
+	m2 := make(map[string]V, m.Len())
+	for k, v := range m.All() {
+		m2[k] = v
+	}
+	return m2
+}
+
+func (m *MapOf[V]) Equal(other any) bool {
+	if b, ok := other.(*ImmutableMapOf[V]); ok && !m.dirty && m.base == b {
+		return true
+	}
+	return equalMapReader(m, other)
+}
+
+func (m *MapOf[V]) MarshalJSON() ([]byte, error) {
+	if !m.dirty {
+		return m.base.MarshalJSON()
+	}
+	return json.Marshal(m.Export())
+}
+
+func (m *MapOf[V]) setOverwrite(k string, o typedOverwrite[V]) {
+	if m.overwrites == nil {
+		m.overwrites = make(map[string]typedOverwrite[V])
+	}
+	m.overwrites[k] = o
+}
+
+func (m *MapOf[V]) getAny(key string) (any, bool) {
+	v, ok := m.Get(key)
+	if !ok {
+		return nil, false
+	}
+	iv, _ := isContainer(v)
+	return iv, true
+}
+
+func (m *MapOf[V]) allAny() iter.Seq2[string, any] {
+	return typedAllAny(m.All())
+}
+
+// At retrieves the element at the specified index. Like a native Go slice, if
+// the index is out of bounds, this function panics.
+//
+// This has O(1) average time complexity.
+func (s *SliceOf[T]) At(index int) T {
+	if index < 0 {
+		panic(fmt.Sprintf("*green.SliceOf.At: index out of range [%d]", index))
+	}
+	if index >= s.Len() {
+		panic(fmt.Sprintf("*green.SliceOf.At: index out of range [%d] with length %d", index, s.Len()))
+	}
+
+	// in prepends?
+	if index < len(s.prepends) {
+		return s.prepends[s.prependIndex(index)]
+	}
+	index -= len(s.prepends)
+
+	// in base/overwrites?
+	if index < s.base.Len() {
+		if v, ok := s.overwrites[index+s.overwriteOffset]; ok {
+			return v
+		}
+		return s.base.At(index)
+	}
+
+	// in appends
+	return s.appends[index-s.base.Len()]
+}
+
+// Set sets the value at the specified index in the SliceOf. Like a native Go
+// slice, if the index is out of bounds, this function panics.
+//
+// This has O(1) average time complexity.
+func (s *SliceOf[T]) Set(index int, val T) {
+	if index < 0 {
+		panic(fmt.Sprintf("*green.SliceOf.Set: index out of range [%d]", index))
+	}
+	if index >= s.Len() {
+		panic(fmt.Sprintf("*green.SliceOf.Set: index out of range [%d] with length %d", index, s.Len()))
+	}
+
+	s.dirty = true
+
+	// in prepends?
+	if index < len(s.prepends) {
+		s.prepends[s.prependIndex(index)] = val
+		return
+	}
+	index -= len(s.prepends)
+
+	// in overwrites?
+	if index < s.base.Len() {
+		if s.overwrites == nil {
+			s.overwrites = make(map[int]T)
+		}
+		s.overwrites[index+s.overwriteOffset] = val
+		return
+	}
+
+	// in appends
+	s.appends[index-s.base.Len()] = val
+}
+
+// Len returns the number of elements in the SliceOf. If the SliceOf is nil, it
+// returns 0.
+//
+// This has O(1) time complexity.
+func (s *SliceOf[T]) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	return len(s.prepends) + len(s.appends) + s.base.Len()
+}
+
+// Push appends the given value to the end of the SliceOf. If the SliceOf is
+// nil, this panics.
+//
+// This has O(1) average time complexity (the same complexity as Go's native
+// append function).
+func (s *SliceOf[T]) Push(val T) {
+	if s == nil {
+		panic("*green.SliceOf.Push: push to nil slice")
+	}
+
+	s.appends = append(s.appends, val)
+	s.dirty = true
+}
+
+// PushFront prepends the given value to the front of the SliceOf. If the
+// SliceOf is nil, this panics.
+//
+// This has O(1) average time complexity (the same complexity as Go's native
+// append function).
+func (s *SliceOf[T]) PushFront(val T) {
+	if s == nil {
+		panic("*green.SliceOf.PushFront: push-front to nil slice")
+	}
+
+	s.prepends = append(s.prepends, val)
+	s.dirty = true
+}
+
+// ReSlice adjusts the bounds of the SliceOf to the given left index
+// (inclusive) and right index (exclusive), like Slice.ReSlice.
+//
+// This has O(1) time complexity.
+func (s *SliceOf[T]) ReSlice(left, right int) {
+	s2 := s.subSlice(left, right, "ReSlice")
+	if s2 == s {
+		return
+	}
+	*s = *s2
+	s.dirty = true
+}
+
+// SubSlice returns a new SliceOf representing the subslice of the original
+// SliceOf from the given left index (inclusive) to the right index
+// (exclusive), like Slice.SubSlice.
+//
+// This has O(1) time complexity.
+func (s *SliceOf[T]) SubSlice(left, right int) *SliceOf[T] {
+	return s.subSlice(left, right, "SubSlice")
+}
+
+// All returns an iterator over all index, value pairs in the SliceOf in order.
+// This function yields nothing if the SliceOf is nil.
+//
+// This has O(k') average time complexity, where k' is the number of elements in
+// the SliceOf which get iterated over.
+func (s *SliceOf[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if s == nil {
+			return
+		}
+		i := 0
+		for j := s.prependIndex(0); j >= 0; j-- {
+			if !yield(i, s.prepends[j]) {
+				return
+			}
+			i++
+		}
+		for j, v := range s.base.All() {
+			if v2, ok := s.overwrites[j+s.overwriteOffset]; ok {
+				v = v2
+			}
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+		for _, v := range s.appends {
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// Immutable returns an immutable version of the SliceOf. Subsequent mutations
+// to the SliceOf do not affect the returned ImmutableSliceOf. If the SliceOf
+// is nil, this returns nil.
+//
+// This has O(k) time complexity, where k is the number of elements in the
+// SliceOf.
+func (s *SliceOf[T]) Immutable() *ImmutableSliceOf[T] {
+	if s == nil {
+		return nil
+	}
+	if !s.dirty {
+		return s.base
+	}
+
+	return &ImmutableSliceOf[T]{base: s.Export()}
+}
+
+// Clone returns a copy of the SliceOf. Subsequent mutations to the clone do
+// not affect the original SliceOf, and vice versa. If the SliceOf is nil, this
+// returns nil.
+//
+// This has O(w+a+p) time complexity, where w is the number of distinct indexes
+// Set on the SliceOf, and a and p are the numbers of pushed and front-pushed
+// elements.
+func (s *SliceOf[T]) Clone() *SliceOf[T] {
+	if s == nil {
+		return nil
+	}
+
+	return &SliceOf[T]{
+		base:            s.base,
+		overwrites:      maps.Clone(s.overwrites),
+		overwriteOffset: s.overwriteOffset,
+		appends:         slices.Clone(s.appends),
+		prepends:        slices.Clone(s.prepends),
+		dirty:           s.dirty,
+	}
+}
+
+// Export returns a copy of the SliceOf as a native Go slice. Elements are
+// copied shallowly. If the SliceOf is nil, this returns nil.
+//
+// This has O(k) time complexity, where k is the number of elements in the
+// SliceOf.
+func (s *SliceOf[T]) Export() []T {
+	if s == nil {
+		return nil
+	}
+
+	s2 := make([]T, s.Len())
+	for i, v := range s.All() {
+		s2[i] = v
+	}
+	return s2
+}
+
+func (s *SliceOf[T]) Equal(other any) bool {
+	if b, ok := other.(*ImmutableSliceOf[T]); ok && !s.dirty && s.base == b {
+		return true
+	}
+	return equalSliceReader(s, other)
+}
+
+func (s *SliceOf[T]) MarshalJSON() ([]byte, error) {
+	if !s.dirty {
+		return s.base.MarshalJSON()
+	}
+	return json.Marshal(s.Export())
+}
+
+func (s *SliceOf[T]) atAny(index int) any {
+	iv, _ := isContainer(s.At(index))
+	return iv
+}
+
+func (s *SliceOf[T]) prependIndex(i int) int {
+	return len(s.prepends) - 1 - i
+}
+
+func (s *SliceOf[T]) subSlice(l, r int, funcName string) *SliceOf[T] {
+	if l < 0 {
+		panic(fmt.Sprintf("*green.SliceOf.%s: index out of range [%d]", funcName, l))
+	}
+	if r > s.Len() {
+		panic(fmt.Sprintf("*green.SliceOf.%s: index out of range [%d] with length %d", funcName, r, s.Len()))
+	}
+	if l > r {
+		panic(fmt.Sprintf("*green.SliceOf.%s: slice bounds out of range [%d:%d]", funcName, l, r))
+	}
+
+	if l == 0 && r == s.Len() {
+		return s
+	}
+
+	var (
+		newPrepends        = s.prepends
+		newBase            = s.base
+		newOverwriteOffset = s.overwriteOffset
+		newAppends         = s.appends
+	)
+
+	// trim from the front
+	lToTrim := l
+	if lToTrim > 0 {
+		origPrependsLen := len(s.prepends)
+		newPrepends = s.prepends[:max(0, s.prependIndex(lToTrim)+1)]
+		lToTrim -= origPrependsLen - len(newPrepends)
+	}
+
+	// trim from the back
+	rToTrim := s.Len() - r
+	if rToTrim > 0 {
+		origAppendsLen := len(s.appends)
+		newAppends = s.appends[:max(0, len(s.appends)-rToTrim)]
+		rToTrim -= origAppendsLen - len(newAppends)
+	}
+
+	// adjust base
+	if lToTrim > 0 || rToTrim > 0 {
+		lBaseToTrim := min(lToTrim, s.base.Len())
+		rBaseToTrim := min(rToTrim, s.base.Len()-lBaseToTrim)
+		newOverwriteOffset = s.overwriteOffset + lBaseToTrim
+		newBase = s.base.SubSlice(lBaseToTrim, s.base.Len()-rBaseToTrim)
+
+		lToTrim -= lBaseToTrim
+		rToTrim -= rBaseToTrim
+
+		// adjust prepends/appends if we overflow from base (only 1 branch can
+		// be true)
+		if lToTrim > 0 {
+			newAppends = newAppends[lToTrim:]
+		} else if rToTrim > 0 {
+			newPrepends = newPrepends[rToTrim:]
+		}
+	}
+
+	// clip so that pushing onto the subslice can't overwrite elements of the
+	// original past its end
+	newPrepends = slices.Clip(newPrepends)
+	newAppends = slices.Clip(newAppends)
+
+	return &SliceOf[T]{
+		base:            newBase,
+		overwrites:      s.overwrites,
+		overwriteOffset: newOverwriteOffset,
+		appends:         newAppends,
+		prepends:        newPrepends,
+		dirty:           s.dirty,
+	}
+}
+
+// typedAllAny adapts an iterator over typed values to one over values as they
+// would be returned by an ImmutableMap.
+func typedAllAny[V any](seq iter.Seq2[string, V]) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for k, v := range seq {
+			iv, _ := isContainer(v)
+			if !yield(k, iv) {
+				return
+			}
+		}
+	}
+}
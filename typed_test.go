@@ -0,0 +1,197 @@
+package green
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapOf(t *testing.T) {
+	t.Run("Get, Has, Len, All", func(t *testing.T) {
+		im := NewImmutableMapOf(map[string]string{"breed": "Great Pyrenees", "name": "Bruno"})
+
+		v, ok := im.Get("breed")
+		require.True(t, ok)
+		assert.Equal(t, "Great Pyrenees", v)
+		_, ok = im.Get("missing")
+		assert.False(t, ok)
+		assert.True(t, im.Has("name"))
+		assert.Equal(t, 2, im.Len())
+
+		var nilMap *ImmutableMapOf[string]
+		assert.Equal(t, 0, nilMap.Len())
+		assert.False(t, nilMap.Has("breed"))
+		assert.Nil(t, nilMap.Mutable())
+	})
+
+	t.Run("copy on write", func(t *testing.T) {
+		source := map[string]int{"a": 1, "b": 2, "c": 3}
+		im := NewImmutableMapOf(source)
+		mut := im.Mutable()
+		assert.Same(t, im, mut.Immutable())
+
+		mut.Set("a", 10)
+		mut.Set("d", 4)
+		mut.Delete("b")
+		mut.Delete("missing")
+		assert.Equal(t, 3, mut.Len())
+		assert.Equal(t, map[string]int{"a": 10, "c": 3, "d": 4}, mut.Export())
+		assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, im.Export())
+		assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, source)
+
+		im2 := mut.Immutable()
+		mut.Set("e", 5)
+		assert.Equal(t, map[string]int{"a": 10, "c": 3, "d": 4}, im2.Export())
+		assert.Equal(t, 3, im2.Len())
+
+		mut2 := im2.Mutable()
+		mut2.Set("b", 20)
+		assert.Equal(t, map[string]int{"a": 10, "b": 20, "c": 3, "d": 4}, mut2.Immutable().Export())
+
+		clone := mut2.Clone()
+		clone.Delete("a")
+		assert.True(t, mut2.Has("a"))
+		assert.False(t, clone.Has("a"))
+	})
+
+	t.Run("Equal", func(t *testing.T) {
+		im := NewImmutableMapOf(map[string]string{"a": "x", "b": "y"})
+		untyped := NewImmutableMap(map[string]any{"a": "x", "b": "y"})
+
+		assert.True(t, im.Equal(untyped))
+		assert.True(t, untyped.Equal(im))
+		assert.True(t, Equal(untyped.Mutable(), im.Mutable()))
+		assert.True(t, Equal(im, map[string]any{"a": "x", "b": "y"}))
+		assert.True(t, im.Equal(im.Mutable()))
+		assert.False(t, im.Equal(NewImmutableMap(map[string]any{"a": "x"})))
+
+		mut := im.Mutable()
+		mut.Set("b", "z")
+		assert.False(t, im.Equal(mut))
+		assert.False(t, untyped.Equal(mut))
+
+		nested := NewImmutableMapOf(map[string][]any{"a": {1, map[string]any{"b": 2}}})
+		assert.True(t, nested.Equal(map[string]any{"a": []any{1, map[string]any{"b": 2}}}))
+		assert.True(t, Equal(NewImmutableMapOf(map[string][]string{"a": {"x"}}), NewImmutableMapOf(map[string][]string{"a": {"x"}})))
+	})
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		im := NewImmutableMapOf(map[string]int{"b": 2, "a": 1})
+		got, err := json.Marshal(im)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":1,"b":2}`, string(got))
+
+		mut := im.Mutable()
+		mut.Delete("a")
+		got, err = json.Marshal(mut)
+		require.NoError(t, err)
+		assert.Equal(t, `{"b":2}`, string(got))
+	})
+}
+
+func TestSliceOf(t *testing.T) {
+	t.Run("At, Len, All, SubSlice", func(t *testing.T) {
+		is := NewImmutableSliceOf([]string{"a", "b", "c", "d"})
+		assert.Equal(t, "b", is.At(1))
+		assert.Equal(t, 4, is.Len())
+		assert.Panics(t, func() { is.At(4) })
+
+		sub := is.SubSlice(1, 3)
+		assert.Equal(t, []string{"b", "c"}, sub.Export())
+		assert.Same(t, is, is.SubSlice(0, 4))
+
+		var got []string
+		for _, v := range is.All() {
+			got = append(got, v)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d"}, got)
+	})
+
+	t.Run("copy on write", func(t *testing.T) {
+		source := []int{1, 2, 3}
+		is := NewImmutableSliceOf(source)
+		mut := is.Mutable()
+		assert.Same(t, is, mut.Immutable())
+
+		mut.Set(0, 10)
+		mut.Push(4)
+		mut.PushFront(0)
+		mut.PushFront(-1)
+		assert.Equal(t, []int{-1, 0, 10, 2, 3, 4}, mut.Export())
+		assert.Equal(t, []int{1, 2, 3}, is.Export())
+		assert.Equal(t, []int{1, 2, 3}, source)
+
+		im2 := mut.Immutable()
+		mut.Set(1, 100)
+		assert.Equal(t, []int{-1, 0, 10, 2, 3, 4}, im2.Export())
+
+		clone := mut.Clone()
+		clone.Set(0, -100)
+		assert.Equal(t, -1, mut.At(0))
+	})
+
+	t.Run("ReSlice", func(t *testing.T) {
+		for l := range 7 {
+			for r := l; r <= 6; r++ {
+				mut := NewImmutableSliceOf([]int{1, 2, 3}).Mutable()
+				mut.Set(1, 20)
+				mut.Push(4)
+				mut.Push(5)
+				mut.PushFront(0)
+				expect := []int{0, 1, 20, 3, 4, 5}
+
+				sub := mut.SubSlice(l, r)
+				assert.Equal(t, expect[l:r], sub.Export(), "[%d:%d]", l, r)
+
+				mut.ReSlice(l, r)
+				assert.Equal(t, expect[l:r], mut.Export(), "[%d:%d]", l, r)
+				assert.Equal(t, r-l, mut.Len())
+			}
+		}
+	})
+
+	t.Run("pushing onto a SubSlice", func(t *testing.T) {
+		mut := NewImmutableSliceOf([]int{3}).Mutable()
+		mut.Push(4)
+		mut.Push(5)
+		mut.Push(6)
+		mut.PushFront(2)
+		mut.PushFront(1)
+		mut.PushFront(0)
+
+		sub := mut.SubSlice(1, 6)
+		sub.Push(50)
+		sub.PushFront(10)
+		assert.Equal(t, []int{10, 1, 2, 3, 4, 5, 50}, sub.Export())
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6}, mut.Export())
+	})
+
+	t.Run("Equal", func(t *testing.T) {
+		is := NewImmutableSliceOf([]string{"a", "b"})
+		untyped := NewImmutableSlice([]any{"a", "b"})
+
+		assert.True(t, is.Equal(untyped))
+		assert.True(t, untyped.Equal(is))
+		assert.True(t, Equal(untyped.Mutable(), is.Mutable()))
+		assert.True(t, is.Equal([]any{"a", "b"}))
+		assert.False(t, is.Equal([]any{"a"}))
+
+		maps := NewImmutableSliceOf([]map[string]any{{"a": 1}})
+		assert.True(t, maps.Equal(NewImmutableSlice([]any{map[string]any{"a": 1}})))
+		assert.False(t, maps.Equal(NewImmutableSlice([]any{map[string]any{"a": 2}})))
+	})
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		mut := NewImmutableSliceOf([]string{"a"}).Mutable()
+		mut.Push("b")
+		got, err := json.Marshal(mut)
+		require.NoError(t, err)
+		assert.Equal(t, `["a","b"]`, string(got))
+
+		got, err = json.Marshal(mut.Immutable())
+		require.NoError(t, err)
+		assert.Equal(t, `["a","b"]`, string(got))
+	})
+}
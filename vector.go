@@ -0,0 +1,383 @@
+package green
+
+import (
+	"iter"
+	"slices"
+)
+
+// vectorWidth is the maximum number of values in a leaf, and of children in a
+// branch, of a vector.
+const vectorWidth = 32
+
+type (
+	// SliceOption configures NewImmutableSlice.
+	SliceOption func(*sliceConfig)
+
+	sliceConfig struct {
+		vector bool
+	}
+
+	// vector is a persistent relaxed radix balanced tree (RRB-tree) of values.
+	// All leaves are at the same height, but nodes may hold fewer than
+	// vectorWidth entries, so branches record the cumulative sizes of their
+	// children. Updates return a new vector which shares all untouched nodes
+	// with the original.
+	vector struct {
+		// root is nil if the vector is empty.
+		root   *vectorNode
+		height int
+	}
+
+	// vectorNode is a leaf holding values, if height is 0, or a branch holding
+	// children otherwise. Nodes are never modified once they are part of a
+	// vector.
+	vectorNode struct {
+		values   []any
+		children []*vectorNode
+		// sizes[i] is the number of values in children[0:i+1].
+		sizes []int
+	}
+)
+
+// WithVector makes NewImmutableSlice store the slice in a persistent vector
+// (an RRB-tree) instead of wrapping it. Building the vector copies the top
+// level of the slice, but ImmutableSlices canonized from Slices derived from
+// it, and their SubSlices, are vectors as well, which share all untouched
+// nodes with the original. Thus Mutable, a few calls to Set, Push, PushFront,
+// or ReSlice, and Immutable cost O(w*log(n)) rather than O(n), where w is the
+// number of values written and n is the number of elements in the slice. At
+// costs O(log(n)).
+//
+// This suits very large slices which undergo frequent small edits, such as
+// logs which are appended to. The option only applies to the top level of the
+// slice; nested slices are wrapped as usual.
+func WithVector() SliceOption {
+	return func(cfg *sliceConfig) {
+		cfg.vector = true
+	}
+}
+
+// newVector builds a vector holding the values in s, converted by f.
+func newVector(s []any, f func(any) ImmutableValue) *vector {
+	v := &vector{}
+	if len(s) == 0 {
+		return v
+	}
+
+	nodes := make([]*vectorNode, 0, (len(s)+vectorWidth-1)/vectorWidth)
+	for chunk := range slices.Chunk(s, vectorWidth) {
+		values := make([]any, len(chunk))
+		for i, val := range chunk {
+			values[i] = f(val)
+		}
+		nodes = append(nodes, &vectorNode{values: values})
+	}
+	for len(nodes) > 1 {
+		parents := make([]*vectorNode, 0, (len(nodes)+vectorWidth-1)/vectorWidth)
+		for chunk := range slices.Chunk(nodes, vectorWidth) {
+			parents = append(parents, newVectorBranch(slices.Clone(chunk)))
+		}
+		nodes = parents
+		v.height++
+	}
+	v.root = nodes[0]
+	return v
+}
+
+func newVectorBranch(children []*vectorNode) *vectorNode {
+	n := &vectorNode{children: children}
+	n.updateSizes(0)
+	return n
+}
+
+func (v *vector) len() int {
+	if v == nil {
+		return 0
+	}
+	return v.root.len()
+}
+
+// get returns the value at the index, which must be in bounds.
+func (v *vector) get(index int) any {
+	n := v.root
+	for range v.height {
+		i := n.childAt(index)
+		if i > 0 {
+			index -= n.sizes[i-1]
+		}
+		n = n.children[i]
+	}
+	return n.values[index]
+}
+
+// set returns a new vector with the value at the index, which must be in
+// bounds, replaced.
+func (v *vector) set(index int, val any) *vector {
+	return &vector{root: v.root.set(v.height, index, val), height: v.height}
+}
+
+// push returns a new vector with the value appended.
+func (v *vector) push(val any) *vector {
+	if v.root == nil {
+		return &vector{root: &vectorNode{values: []any{val}}}
+	}
+	root, overflow := v.root.push(v.height, val)
+	return newVectorRoot(root, overflow, v.height)
+}
+
+// pushFront returns a new vector with the value prepended.
+func (v *vector) pushFront(val any) *vector {
+	if v.root == nil {
+		return &vector{root: &vectorNode{values: []any{val}}}
+	}
+	root, overflow := v.root.pushFront(v.height, val)
+	if overflow != nil {
+		root, overflow = overflow, root
+	}
+	return newVectorRoot(root, overflow, v.height)
+}
+
+// concat returns a new vector holding the values of v followed by those of
+// other. The shorter tree is attached to the edge of the taller one at its own
+// height, so this has O(log(n)) time complexity.
+func (v *vector) concat(other *vector) *vector {
+	switch {
+	case other.root == nil:
+		return v
+	case v.root == nil:
+		return other
+	case v.height >= other.height:
+		root, overflow := v.root.appendNode(v.height, other.root, other.height)
+		return newVectorRoot(root, overflow, v.height)
+	default:
+		root, overflow := other.root.prependNode(other.height, v.root, v.height)
+		if overflow != nil {
+			root, overflow = overflow, root
+		}
+		return newVectorRoot(root, overflow, other.height)
+	}
+}
+
+// slice returns a new vector holding the values from left (inclusive) to right
+// (exclusive), which must be in bounds.
+func (v *vector) slice(left, right int) *vector {
+	if left == right {
+		return &vector{}
+	}
+	root, height := v.root.slice(v.height, left, right), v.height
+	// drop branches left with a single child
+	for height > 0 && len(root.children) == 1 {
+		root = root.children[0]
+		height--
+	}
+	return &vector{root: root, height: height}
+}
+
+func (v *vector) all() iter.Seq2[int, any] {
+	return func(yield func(int, any) bool) {
+		if v == nil || v.root == nil {
+			return
+		}
+		i := 0
+		v.root.all(v.height, func(val any) bool {
+			ok := yield(i, val)
+			i++
+			return ok
+		})
+	}
+}
+
+// newVectorRoot returns the vector rooted at root, of the given height, or, if
+// the root overflowed, at a new branch holding both.
+func newVectorRoot(root, overflow *vectorNode, height int) *vector {
+	if overflow == nil {
+		return &vector{root: root, height: height}
+	}
+	return &vector{root: newVectorBranch([]*vectorNode{root, overflow}), height: height + 1}
+}
+
+func (n *vectorNode) len() int {
+	switch {
+	case n == nil:
+		return 0
+	case n.children != nil:
+		return n.sizes[len(n.sizes)-1]
+	default:
+		return len(n.values)
+	}
+}
+
+// childAt returns the index of the child holding the value at the index.
+func (n *vectorNode) childAt(index int) int {
+	i, found := slices.BinarySearch(n.sizes, index)
+	if found {
+		// sizes are cumulative, so the value is the first of the next child
+		i++
+	}
+	return i
+}
+
+// updateSizes recomputes the cumulative sizes from the child at index from.
+func (n *vectorNode) updateSizes(from int) {
+	if n.sizes == nil {
+		n.sizes = make([]int, 0, len(n.children))
+	}
+	n.sizes = n.sizes[:from]
+	total := 0
+	if from > 0 {
+		total = n.sizes[from-1]
+	}
+	for _, c := range n.children[from:] {
+		total += c.len()
+		n.sizes = append(n.sizes, total)
+	}
+}
+
+func (n *vectorNode) clone() *vectorNode {
+	return &vectorNode{
+		values:   slices.Clone(n.values),
+		children: slices.Clone(n.children),
+		sizes:    slices.Clone(n.sizes),
+	}
+}
+
+func (n *vectorNode) set(height, index int, val any) *vectorNode {
+	n2 := n.clone()
+	if height == 0 {
+		n2.values[index] = val
+		return n2
+	}
+	i := n.childAt(index)
+	if i > 0 {
+		index -= n.sizes[i-1]
+	}
+	n2.children[i] = n.children[i].set(height-1, index, val)
+	return n2
+}
+
+// push returns a copy of n with the value appended. If n has no room for it,
+// n itself and a new sibling of the same height holding the value are
+// returned.
+func (n *vectorNode) push(height int, val any) (*vectorNode, *vectorNode) {
+	if height == 0 {
+		if len(n.values) == vectorWidth {
+			return n, &vectorNode{values: []any{val}}
+		}
+		n2 := n.clone()
+		n2.values = append(n2.values, val)
+		return n2, nil
+	}
+	last := len(n.children) - 1
+	child, overflow := n.children[last].push(height-1, val)
+	return n.replaceLast(child, overflow)
+}
+
+// pushFront is like push, but prepends the value. The new sibling, if any,
+// belongs before n.
+func (n *vectorNode) pushFront(height int, val any) (*vectorNode, *vectorNode) {
+	if height == 0 {
+		if len(n.values) == vectorWidth {
+			return n, &vectorNode{values: []any{val}}
+		}
+		return &vectorNode{values: slices.Insert(slices.Clone(n.values), 0, val)}, nil
+	}
+	child, overflow := n.children[0].pushFront(height-1, val)
+	return n.replaceFirst(child, overflow)
+}
+
+// appendNode is like push, but appends the node sub, of height subHeight,
+// after the last node of that height under n.
+func (n *vectorNode) appendNode(height int, sub *vectorNode, subHeight int) (*vectorNode, *vectorNode) {
+	if height == subHeight {
+		return n, sub
+	}
+	last := len(n.children) - 1
+	child, overflow := n.children[last].appendNode(height-1, sub, subHeight)
+	return n.replaceLast(child, overflow)
+}
+
+// prependNode is like appendNode, but prepends sub.
+func (n *vectorNode) prependNode(height int, sub *vectorNode, subHeight int) (*vectorNode, *vectorNode) {
+	if height == subHeight {
+		return n, sub
+	}
+	child, overflow := n.children[0].prependNode(height-1, sub, subHeight)
+	return n.replaceFirst(child, overflow)
+}
+
+// replaceLast returns a copy of the branch n with its last child replaced, and
+// the overflow sibling of that child, if any, added after it. If n has no room
+// for the overflow, it is returned wrapped in a new branch.
+func (n *vectorNode) replaceLast(child, overflow *vectorNode) (*vectorNode, *vectorNode) {
+	n2 := n.clone()
+	last := len(n2.children) - 1
+	n2.children[last] = child
+	if overflow != nil && len(n2.children) < vectorWidth {
+		n2.children = append(n2.children, overflow)
+		overflow = nil
+	}
+	n2.updateSizes(last)
+	if overflow != nil {
+		return n2, newVectorBranch([]*vectorNode{overflow})
+	}
+	return n2, nil
+}
+
+// replaceFirst is like replaceLast, but for the first child, with the overflow
+// sibling added before it.
+func (n *vectorNode) replaceFirst(child, overflow *vectorNode) (*vectorNode, *vectorNode) {
+	n2 := n.clone()
+	n2.children[0] = child
+	if overflow != nil && len(n2.children) < vectorWidth {
+		n2.children = slices.Insert(n2.children, 0, overflow)
+		overflow = nil
+	}
+	n2.updateSizes(0)
+	if overflow != nil {
+		return n2, newVectorBranch([]*vectorNode{overflow})
+	}
+	return n2, nil
+}
+
+// slice returns a node holding the values of n from left (inclusive) to right
+// (exclusive), which must not be empty.
+func (n *vectorNode) slice(height, left, right int) *vectorNode {
+	if left == 0 && right == n.len() {
+		return n
+	}
+	if height == 0 {
+		return &vectorNode{values: n.values[left:right:right]}
+	}
+
+	first, last := n.childAt(left), n.childAt(right-1)
+	children := slices.Clone(n.children[first : last+1])
+	offset := 0
+	if first > 0 {
+		offset = n.sizes[first-1]
+	}
+	if first == last {
+		children[0] = children[0].slice(height-1, left-offset, right-offset)
+	} else {
+		children[0] = children[0].slice(height-1, left-offset, children[0].len())
+		lastOffset := n.sizes[last-1]
+		children[len(children)-1] = children[len(children)-1].slice(height-1, 0, right-lastOffset)
+	}
+	return newVectorBranch(children)
+}
+
+func (n *vectorNode) all(height int, yield func(any) bool) bool {
+	if height == 0 {
+		for _, v := range n.values {
+			if !yield(v) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range n.children {
+		if !c.all(height-1, yield) {
+			return false
+		}
+	}
+	return true
+}
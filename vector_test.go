@@ -0,0 +1,201 @@
+package green
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVector(t *testing.T) {
+	identity := func(v any) ImmutableValue { return v }
+	ints := func(from, to int) []any {
+		s := make([]any, 0, to-from)
+		for i := from; i < to; i++ {
+			s = append(s, i)
+		}
+		return s
+	}
+
+	// checkVector asserts that the vector holds want and that its size tables
+	// are consistent.
+	checkVector := func(t *testing.T, want []any, v *vector) {
+		t.Helper()
+		require.Equal(t, len(want), v.len())
+		var check func(n *vectorNode, height int) int
+		check = func(n *vectorNode, height int) int {
+			if height == 0 {
+				require.Nil(t, n.children)
+				require.NotEmpty(t, n.values)
+				require.LessOrEqual(t, len(n.values), vectorWidth)
+				return len(n.values)
+			}
+			require.NotEmpty(t, n.children)
+			require.LessOrEqual(t, len(n.children), vectorWidth)
+			total := 0
+			for i, c := range n.children {
+				total += check(c, height-1)
+				require.Equal(t, total, n.sizes[i])
+			}
+			return total
+		}
+		if v.root != nil {
+			check(v.root, v.height)
+		}
+		got := make([]any, 0, v.len())
+		for i, val := range v.all() {
+			require.Equal(t, len(got), i)
+			got = append(got, val)
+		}
+		require.Equal(t, want, slices.Clip(got))
+		for i, val := range want {
+			require.Equal(t, val, v.get(i))
+		}
+	}
+
+	t.Run("operations match a native slice", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(3, 4))
+		want := ints(0, 1000)
+		v := newVector(want, identity)
+		checkVector(t, want, v)
+		versions := []*vector{v}
+		snapshots := [][]any{slices.Clone(want)}
+
+		next := 1000
+		for range 300 {
+			switch op := rng.IntN(6); {
+			case op == 0:
+				v = v.push(next)
+				want = append(want, next)
+			case op == 1:
+				v = v.pushFront(next)
+				want = slices.Insert(want, 0, any(next))
+			case op == 2 && len(want) > 0:
+				i := rng.IntN(len(want))
+				v = v.set(i, next)
+				want[i] = next
+			case op == 3:
+				l := rng.IntN(len(want) + 1)
+				r := l + rng.IntN(len(want)-l+1)
+				v = v.slice(l, r)
+				want = slices.Clone(want[l:r])
+			case op == 4:
+				other := ints(next, next+rng.IntN(100))
+				v = v.concat(newVector(other, identity))
+				want = append(want, other...)
+			default:
+				other := ints(next, next+rng.IntN(2000))
+				v = newVector(other, identity).concat(v)
+				want = append(other, want...)
+			}
+			next += 2000
+			checkVector(t, want, v)
+			versions = append(versions, v)
+			snapshots = append(snapshots, slices.Clone(want))
+		}
+
+		// earlier versions are unaffected
+		for i, v := range versions {
+			checkVector(t, snapshots[i], v)
+		}
+	})
+
+	t.Run("push fills leaves", func(t *testing.T) {
+		v := newVector(nil, identity)
+		for i := range 5000 {
+			v = v.push(i)
+		}
+		want := ints(0, 5000)
+		checkVector(t, want, v)
+		assert.Equal(t, 2, v.height)
+
+		v = newVector(nil, identity)
+		for i := range 5000 {
+			v = v.pushFront(4999 - i)
+		}
+		checkVector(t, want, v)
+		assert.Equal(t, 2, v.height)
+	})
+
+	t.Run("ImmutableSlice", func(t *testing.T) {
+		src := ints(0, 50000)
+		src[7] = map[string]any{"name": "Sam"}
+		is := NewImmutableSlice(slices.Clone(src), WithVector())
+		owner := is.At(7)
+		firstLeaf := is.vec.root
+		for range is.vec.height {
+			firstLeaf = firstLeaf.children[0]
+		}
+
+		mut := is.Mutable()
+		mut.Push("end")
+		mut.PushFront("start")
+		mut.Set(2, "two")
+		is2 := mut.Immutable()
+		require.NotNil(t, is2.vec)
+		assert.Equal(t, 50002, is2.Len())
+		assert.Equal(t, "start", is2.At(0))
+		assert.Equal(t, "two", is2.At(2))
+		assert.Equal(t, "end", is2.At(50001))
+		assert.Same(t, owner, is2.At(8), "nested containers are shared")
+		assert.Equal(t, src, is.Export())
+
+		// untouched leaves are shared
+		leaf := is2.vec.root
+		for range is2.vec.height {
+			leaf = leaf.children[len(leaf.children)/2]
+		}
+		assert.True(t, slices.Contains(collectLeaves(is.vec.root, is.vec.height), leaf))
+		assert.False(t, slices.Contains(collectLeaves(is2.vec.root, is2.vec.height), firstLeaf))
+
+		// nested mutations and reslicing
+		mut = is2.Mutable()
+		mustGetMapFromSlice(t, 8, mut).Set("name", "Alex")
+		mut.ReSlice(1, 20)
+		mut.Push(map[string]any{"name": "Kim"})
+		is3 := mut.Immutable()
+		require.NotNil(t, is3.vec)
+		want := slices.Clone(src[:19])
+		want[1] = "two"
+		want[7] = map[string]any{"name": "Alex"}
+		want = append(want, map[string]any{"name": "Kim"})
+		assert.Equal(t, want, is3.Export())
+		assert.Equal(t, map[string]any{"name": "Sam"}, is2.At(8).(*ImmutableMap).Export())
+
+		sub := is2.SubSlice(5, 10)
+		require.NotNil(t, sub.vec)
+		assert.Equal(t, []any{4, 5, 6, map[string]any{"name": "Sam"}, 8}, sub.Export())
+		assert.True(t, Equal(sub, []any{4, 5, 6, map[string]any{"name": "Sam"}, 8}))
+		assert.True(t, Equal(is2, is2.Mutable().Immutable()))
+
+		b, err := json.Marshal(sub)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[4,5,6,{"name":"Sam"},8]`, string(b))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		is := NewImmutableSlice(nil, WithVector())
+		assert.Equal(t, 0, is.Len())
+		assert.Equal(t, []any{}, is.Export())
+		mut := is.Mutable()
+		mut.Push(1)
+		mut.PushFront(0)
+		assert.Equal(t, []any{0, 1}, mut.Immutable().Export())
+		mut.ReSlice(1, 1)
+		assert.Equal(t, []any{}, mut.Immutable().Export())
+	})
+}
+
+func collectLeaves(n *vectorNode, height int) []*vectorNode {
+	if height == 0 {
+		return []*vectorNode{n}
+	}
+	var leaves []*vectorNode
+	for _, c := range n.children {
+		leaves = append(leaves, collectLeaves(c, height-1)...)
+	}
+	return leaves
+}
@@ -0,0 +1,166 @@
+package green
+
+import (
+	"slices"
+	"sync"
+)
+
+type (
+	// RefChange describes the publication of a new ImmutableMap through a Ref.
+	RefChange struct {
+		Old, New *ImmutableMap
+	}
+
+	// WatchOption configures Ref.Watch and Ref.Subscribe.
+	WatchOption func(*watchConfig)
+
+	watchConfig struct {
+		pointer  string
+		filtered bool
+	}
+
+	watcher struct {
+		// tokens is the parsed path prefix, if filtered.
+		tokens   []string
+		filtered bool
+		notify   func(RefChange)
+	}
+)
+
+// WithPathPrefix restricts notifications to changes to the value at the given
+// JSON Pointer (RFC 6901), including the value appearing or disappearing.
+// Values are compared with Equal, so unchanged subtrees which are shared
+// between the old and new ImmutableMap are skipped in O(1).
+func WithPathPrefix(pointer string) WatchOption {
+	return func(c *watchConfig) {
+		c.pointer = pointer
+		c.filtered = true
+	}
+}
+
+// Watch registers f to be called whenever a new ImmutableMap is published
+// through the Ref by Store, CompareAndSwap, or Update. Publishing the map the
+// Ref already holds is not a change. The returned function unregisters f.
+//
+// f is called synchronously by the publishing goroutine, in publication order,
+// while writes to the Ref are blocked. Thus, f should be quick and must not
+// write to the Ref itself; reading with Load and calling cancel are fine. An
+// error is returned only for an invalid path prefix.
+func (r *Ref) Watch(f func(old, new *ImmutableMap), opts ...WatchOption) (cancel func(), err error) {
+	w, err := newWatcher(func(c RefChange) { f(c.Old, c.New) }, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.addWatcher(w)
+	return sync.OnceFunc(func() { r.removeWatcher(w) }), nil
+}
+
+// Subscribe is like Watch, but delivers changes on the returned channel, which
+// has the given buffer size. Publishing blocks while the buffer is full, so
+// subscribers must keep up with the rate of publication. The returned function
+// unsubscribes and closes the channel; it unblocks any pending publication,
+// and, like the cancel function returned by Watch, may be called from a Watch
+// callback.
+func (r *Ref) Subscribe(buffer int, opts ...WatchOption) (<-chan RefChange, func(), error) {
+	var (
+		ch   = make(chan RefChange, buffer)
+		done = make(chan struct{})
+		// sendMu guards sending on ch against closing it, and closed records
+		// that it was closed
+		sendMu sync.Mutex
+		closed bool
+	)
+	w, err := newWatcher(func(c RefChange) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+
+		if closed {
+			return
+		}
+		select {
+		case ch <- c:
+		case <-done:
+		}
+	}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.addWatcher(w)
+	return ch, sync.OnceFunc(func() {
+		close(done)
+		r.removeWatcher(w)
+		// wait out any notification in flight before closing; unlike r.mu,
+		// sendMu isn't held while other watchers are called
+		sendMu.Lock()
+		defer sendMu.Unlock()
+
+		closed = true
+		close(ch)
+	}), nil
+}
+
+func newWatcher(notify func(RefChange), opts []WatchOption) (*watcher, error) {
+	var cfg watchConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w := &watcher{filtered: cfg.filtered, notify: notify}
+	if cfg.filtered {
+		tokens, err := parsePointer(cfg.pointer)
+		if err != nil {
+			return nil, err
+		}
+		w.tokens = tokens
+	}
+	return w, nil
+}
+
+func (r *Ref) addWatcher(w *watcher) {
+	r.watchersMu.Lock()
+	defer r.watchersMu.Unlock()
+
+	r.watchers = append(r.watchers, w)
+}
+
+func (r *Ref) removeWatcher(w *watcher) {
+	r.watchersMu.Lock()
+	defer r.watchersMu.Unlock()
+
+	// copy, so that the slice can be shared with in-flight notifications
+	r.watchers = slices.DeleteFunc(slices.Clone(r.watchers), func(w2 *watcher) bool {
+		return w2 == w
+	})
+}
+
+// notify calls the watchers interested in the change from old to new. r.mu must
+// be held.
+func (r *Ref) notify(old, new *ImmutableMap) {
+	if old == new {
+		return
+	}
+
+	r.watchersMu.Lock()
+	watchers := r.watchers
+	r.watchersMu.Unlock()
+
+	for _, w := range watchers {
+		if w.changed(old, new) {
+			w.notify(RefChange{Old: old, New: new})
+		}
+	}
+}
+
+func (w *watcher) changed(old, new *ImmutableMap) bool {
+	if !w.filtered {
+		return true
+	}
+
+	oldValue, oldErr := getPathImmutable(old, w.tokens)
+	newValue, newErr := getPathImmutable(new, w.tokens)
+	oldFound, newFound := oldErr == nil, newErr == nil
+	if !oldFound || !newFound {
+		return oldFound != newFound
+	}
+	return !Equal(oldValue, newValue)
+}
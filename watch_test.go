@@ -0,0 +1,156 @@
+package green
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	newSource := func() map[string]any {
+		return map[string]any{
+			"breed": "Great Pyrenees",
+			"owner": map[string]any{"name": "Sam"},
+		}
+	}
+
+	t.Run("Watch", func(t *testing.T) {
+		im := NewImmutableMap(newSource())
+		r := NewRef(im)
+
+		var changes []RefChange
+		cancel, err := r.Watch(func(old, new *ImmutableMap) {
+			changes = append(changes, RefChange{Old: old, New: new})
+		})
+		require.NoError(t, err)
+
+		im2 := r.Update(func(m *Map) { m.Set("age", 6) })
+		r.Store(im2) // not a change
+		assert.False(t, r.CompareAndSwap(im, im))
+		r.Store(nil)
+		cancel()
+		cancel()
+		r.Store(im)
+
+		assert.Equal(t, []RefChange{{Old: im, New: im2}, {Old: im2, New: nil}}, changes)
+	})
+
+	t.Run("WithPathPrefix", func(t *testing.T) {
+		r := NewRef(NewImmutableMap(newSource()))
+
+		var owners, ages []RefChange
+		_, err := r.Watch(func(old, new *ImmutableMap) {
+			owners = append(owners, RefChange{Old: old, New: new})
+		}, WithPathPrefix("/owner"))
+		require.NoError(t, err)
+		_, err = r.Watch(func(old, new *ImmutableMap) {
+			ages = append(ages, RefChange{Old: old, New: new})
+		}, WithPathPrefix("/age"))
+		require.NoError(t, err)
+
+		r.Update(func(m *Map) { m.Set("breed", "Akbash") })
+		assert.Empty(t, owners)
+		assert.Empty(t, ages)
+
+		// an equal value is not a change
+		r.Update(func(m *Map) { m.Set("owner", map[string]any{"name": "Sam"}) })
+		assert.Empty(t, owners)
+
+		before := r.Load()
+		after := r.Update(func(m *Map) {
+			require.NoError(t, m.SetPath("/owner/name", "Alex"))
+		})
+		assert.Equal(t, []RefChange{{Old: before, New: after}}, owners)
+
+		r.Update(func(m *Map) { m.Set("age", 6) })
+		r.Update(func(m *Map) { m.Delete("age") })
+		assert.Len(t, ages, 2)
+
+		_, err = r.Watch(func(old, new *ImmutableMap) {}, WithPathPrefix("owner"))
+		assert.ErrorIs(t, err, ErrInvalidPointer)
+	})
+
+	t.Run("cancel from within the callback", func(t *testing.T) {
+		var r Ref
+		calls := 0
+		var cancel func()
+		cancel, err := r.Watch(func(old, new *ImmutableMap) {
+			calls++
+			cancel()
+		})
+		require.NoError(t, err)
+
+		r.Store(NewImmutableMap(nil))
+		r.Store(NewImmutableMap(nil))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		r := NewRef(NewImmutableMap(newSource()))
+		ch, cancel, err := r.Subscribe(0, WithPathPrefix("/count"))
+		require.NoError(t, err)
+
+		const updates = 50
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range updates {
+				r.Update(func(m *Map) {
+					n, _ := m.GetInt64("count")
+					m.Set("count", n+1)
+				})
+			}
+		}()
+
+		for i := range updates {
+			c := <-ch
+			n, err := c.New.GetInt64("count")
+			require.NoError(t, err)
+			assert.Equal(t, int64(i+1), n)
+		}
+		wg.Wait()
+
+		cancel()
+		_, ok := <-ch
+		assert.False(t, ok)
+		r.Update(func(m *Map) { m.Set("count", 0) })
+	})
+
+	t.Run("cancel unblocks a pending publication", func(t *testing.T) {
+		var r Ref
+		ch, cancel, err := r.Subscribe(0)
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.Store(NewImmutableMap(nil))
+		}()
+		cancel()
+		<-done
+		for range ch {
+		}
+	})
+
+	t.Run("unsubscribe from within a Watch callback", func(t *testing.T) {
+		var r Ref
+		ch, unsubscribe, err := r.Subscribe(1)
+		require.NoError(t, err)
+		_, err = r.Watch(func(old, new *ImmutableMap) {
+			unsubscribe()
+		})
+		require.NoError(t, err)
+
+		im := NewImmutableMap(nil)
+		r.Store(im)
+		c, ok := <-ch
+		assert.True(t, ok)
+		assert.Same(t, im, c.New)
+		_, ok = <-ch
+		assert.False(t, ok)
+		r.Store(NewImmutableMap(nil))
+	})
+}